@@ -0,0 +1,111 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// The severities of the assertions. Only failing assertions with the error severity fail the run
+const (
+	SeverityInfo  = "info"
+	SeverityWarn  = "warn"
+	SeverityError = "error"
+)
+
+// Assertion is an assertion expression, optionally named, with a custom message reported when it fails and a severity.
+// In the configuration, an assertion is either the expression itself or an object
+type Assertion struct {
+	Expr     string `json:"expr" yaml:"expr"`
+	Name     string `json:"name,omitempty" yaml:"name"`
+	Message  string `json:"message,omitempty" yaml:"message"`
+	Severity string `json:"severity,omitempty" yaml:"severity"`
+}
+
+// UnmarshalYAML decodes the assertion either from a plain expression or from an object, rejecting unknown keys and
+// severities
+func (a *Assertion) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var source string
+	if err := unmarshal(&source); err == nil {
+		*a = Assertion{Expr: source}
+		return nil
+	}
+	values := map[string]interface{}{}
+	if err := unmarshal(&values); err != nil {
+		return errors.New("assertions must be either expressions or objects")
+	}
+	for key := range values {
+		if !contains([]string{"expr", "name", "message", "severity"}, key) {
+			return fmt.Errorf("unknown assertion key %s", key)
+		}
+	}
+	type plain Assertion
+	if err := unmarshal((*plain)(a)); err != nil {
+		return err
+	}
+	if strings.TrimSpace(a.Expr) == "" {
+		return errors.New("assertion expr is required")
+	}
+	a.Severity = strings.ToLower(a.Severity)
+	if a.Severity != "" && !contains([]string{SeverityInfo, SeverityWarn, SeverityError}, a.Severity) {
+		return fmt.Errorf("unknown assertion severity %s, either info, warn or error", a.Severity)
+	}
+	return nil
+}
+
+// MarshalJSON marshals the assertions having just an expression as the expression itself
+func (a Assertion) MarshalJSON() ([]byte, error) {
+	if a.Name == "" && a.Message == "" && a.Severity == "" {
+		return json.Marshal(a.Expr)
+	}
+	type plain Assertion
+	return json.Marshal(plain(a))
+}
+
+// severity returns the severity of the assertion, error by default
+func (a Assertion) severity() string {
+	if a.Severity == "" {
+		return SeverityError
+	}
+	return a.Severity
+}
+
+// newAssertions turns the expressions into assertions with the default severity
+func newAssertions(sources []string) []Assertion {
+	assertions := make([]Assertion, 0, len(sources))
+	for _, source := range sources {
+		assertions = append(assertions, Assertion{Expr: source})
+	}
+	return assertions
+}
+
+// check builds the result of the assertion expression. When the expression evaluates to a falsy result and the
+// assertion has a custom message, the message replaces the output
+func (e expression) check(success bool, output interface{}) Check {
+	if !success && e.assertion.Message != "" {
+		output = e.assertion.Message
+	}
+	return Check{Success: success, Output: output, Assertion: e.source, Name: e.assertion.Name,
+		Severity: e.assertion.severity()}
+}
+
+// checkError builds the failed result of an assertion expression that could not be compiled or run, or whose result
+// is not supported. The error is the output, even when the assertion has a custom message
+func (e expression) checkError(err error) Check {
+	return Check{Success: false, Output: err.Error(), Assertion: e.source, Name: e.assertion.Name,
+		Severity: e.assertion.severity()}
+}
+
+// label returns the name of the assertion, or its expression when it has no name
+func (c Check) label() string {
+	if c.Name != "" {
+		return c.Name
+	}
+	return c.Assertion
+}
+
+// failed returns true when the check did not pass and its severity fails the run
+func (c Check) failed() bool {
+	return !c.Success && (c.Severity == "" || c.Severity == SeverityError)
+}
@@ -0,0 +1,177 @@
+package main
+
+import (
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// Auth is the authentication configuration of the call. Secrets can refer to environment variables using the ${NAME}
+// syntax, and are never marshalled into the outputs
+type Auth struct {
+	Type     string `json:"type" yaml:"type"`
+	Username string `json:"username,omitempty" yaml:"username"`
+	Password string `json:"-" yaml:"password"`
+	Token    string `json:"-" yaml:"token"`
+	Name     string `json:"name,omitempty" yaml:"name"`
+	Key      string `json:"-" yaml:"key"`
+	In       string `json:"in,omitempty" yaml:"in"`
+}
+
+// validate verifies the authentication configuration is complete
+func (a *Auth) validate() error {
+	if a == nil {
+		return nil
+	}
+	switch a.Type {
+	case "basic", "digest":
+		if a.Username == "" {
+			return fmt.Errorf("%s auth requires a username", a.Type)
+		}
+	case "bearer":
+		if a.Token == "" {
+			return errors.New("bearer auth requires a token")
+		}
+	case "apiKey":
+		if a.Name == "" || a.Key == "" {
+			return errors.New("apiKey auth requires a name and a key")
+		}
+		if a.In != "" && a.In != "header" && a.In != "query" {
+			return errors.New("invalid apiKey location, either 'header' or 'query'")
+		}
+	default:
+		return errors.New("invalid auth type, either 'basic', 'bearer', 'digest' or 'apiKey'")
+	}
+	return nil
+}
+
+// isDigest returns true when the authentication requires the digest challenge/response
+func (a *Auth) isDigest() bool {
+	return a != nil && a.Type == "digest"
+}
+
+// apply applies the authentication to the request. Digest authentication is not applied here, as it requires the
+// challenge first
+func (a *Auth) apply(request *http.Request) {
+	if a == nil {
+		return
+	}
+	switch a.Type {
+	case "basic":
+		request.SetBasicAuth(interpolate(a.Username), interpolate(a.Password))
+	case "bearer":
+		request.Header.Set("Authorization", "Bearer "+interpolate(a.Token))
+	case "apiKey":
+		if a.In == "query" {
+			query := request.URL.Query()
+			query.Set(a.Name, interpolate(a.Key))
+			request.URL.RawQuery = query.Encode()
+		} else {
+			request.Header.Set(a.Name, interpolate(a.Key))
+		}
+	}
+}
+
+// digestAuthorization computes the digest Authorization header responding to the challenge in the WWW-Authenticate
+// header. The MD5 and SHA-256 algorithms are supported, with "auth" quality of protection
+func (a *Auth) digestAuthorization(method string, requestUrl string, header http.Header) (string, error) {
+	challenge := parseDigestChallenge(header.Get("WWW-Authenticate"))
+	if challenge == nil {
+		return "", errors.New("no digest challenge in WWW-Authenticate header")
+	}
+	var newHash func() hash.Hash
+	switch strings.ToUpper(challenge["algorithm"]) {
+	case "", "MD5":
+		newHash = md5.New
+	case "SHA-256":
+		newHash = sha256.New
+	default:
+		return "", fmt.Errorf("unsupported digest algorithm %s", challenge["algorithm"])
+	}
+	digest := func(values ...string) string {
+		h := newHash()
+		h.Write([]byte(strings.Join(values, ":")))
+		return hex.EncodeToString(h.Sum(nil))
+	}
+	parsedUrl, err := url.Parse(requestUrl)
+	if err != nil {
+		return "", err
+	}
+	uri := parsedUrl.RequestURI()
+	username := interpolate(a.Username)
+	ha1 := digest(username, challenge["realm"], interpolate(a.Password))
+	ha2 := digest(method, uri)
+	fields := []string{fmt.Sprintf("username=%q", username), fmt.Sprintf("realm=%q", challenge["realm"]),
+		fmt.Sprintf("nonce=%q", challenge["nonce"]), fmt.Sprintf("uri=%q", uri)}
+	if challenge["qop"] != "" {
+		if !supportsAuthQop(challenge["qop"]) {
+			return "", fmt.Errorf("unsupported digest qop %s", challenge["qop"])
+		}
+		cnonceBytes := make([]byte, 8)
+		_, _ = rand.Read(cnonceBytes)
+		cnonce := hex.EncodeToString(cnonceBytes)
+		response := digest(ha1, challenge["nonce"], "00000001", cnonce, "auth", ha2)
+		fields = append(fields, "qop=auth", "nc=00000001", fmt.Sprintf("cnonce=%q", cnonce),
+			fmt.Sprintf("response=%q", response))
+	} else {
+		fields = append(fields, fmt.Sprintf("response=%q", digest(ha1, challenge["nonce"], ha2)))
+	}
+	if challenge["algorithm"] != "" {
+		fields = append(fields, "algorithm="+challenge["algorithm"])
+	}
+	if challenge["opaque"] != "" {
+		fields = append(fields, fmt.Sprintf("opaque=%q", challenge["opaque"]))
+	}
+	return "Digest " + strings.Join(fields, ", "), nil
+}
+
+// supportsAuthQop returns true when the comma-separated list of qop values contains "auth"
+func supportsAuthQop(qop string) bool {
+	for _, value := range strings.Split(qop, ",") {
+		if strings.TrimSpace(value) == "auth" {
+			return true
+		}
+	}
+	return false
+}
+
+// parseDigestChallenge parses the parameters of a digest WWW-Authenticate header. Returns nil when the header is not
+// a digest challenge
+func parseDigestChallenge(header string) map[string]string {
+	if len(header) < 7 || !strings.EqualFold(header[:7], "digest ") {
+		return nil
+	}
+	challenge := map[string]string{}
+	rest := header[7:]
+	for rest != "" {
+		rest = strings.TrimLeft(rest, " ,")
+		separator := strings.Index(rest, "=")
+		if separator < 0 {
+			break
+		}
+		key := strings.ToLower(strings.TrimSpace(rest[:separator]))
+		rest = rest[separator+1:]
+		value := ""
+		if strings.HasPrefix(rest, "\"") {
+			end := strings.Index(rest[1:], "\"")
+			if end < 0 {
+				value, rest = rest[1:], ""
+			} else {
+				value, rest = rest[1:end+1], rest[end+2:]
+			}
+		} else if end := strings.Index(rest, ","); end >= 0 {
+			value, rest = strings.TrimSpace(rest[:end]), rest[end:]
+		} else {
+			value, rest = strings.TrimSpace(rest), ""
+		}
+		challenge[key] = value
+	}
+	return challenge
+}
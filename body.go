@@ -0,0 +1,146 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"mime/multipart"
+	"net/textproto"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// JsonBody is a YAML structure to be sent as a JSON body
+type JsonBody struct {
+	Value interface{}
+}
+
+// UnmarshalYAML converts the YAML structure into one that can be marshalled into JSON
+func (j *JsonBody) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var v interface{}
+	if err := unmarshal(&v); err != nil {
+		return err
+	}
+	j.Value = jsonCompatible(v)
+	return nil
+}
+
+// MarshalJSON marshals the structure as is
+func (j JsonBody) MarshalJSON() ([]byte, error) {
+	return json.Marshal(j.Value)
+}
+
+// jsonCompatible converts the maps decoded from YAML, having interface{} keys, into maps having string keys
+func jsonCompatible(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[interface{}]interface{}:
+		converted := map[string]interface{}{}
+		for key, item := range v {
+			converted[fmt.Sprint(key)] = jsonCompatible(item)
+		}
+		return converted
+	case []interface{}:
+		converted := make([]interface{}, len(v))
+		for i, item := range v {
+			converted[i] = jsonCompatible(item)
+		}
+		return converted
+	}
+	return value
+}
+
+// Multipart is a multipart/form-data body, made of fields and of file parts read from disk
+type Multipart struct {
+	Fields map[string]string `json:"fields" yaml:"fields"`
+	Files  map[string]string `json:"files" yaml:"files"`
+}
+
+// requestBody is the body sent with the request, along with its content type
+type requestBody struct {
+	data        []byte
+	contentType string
+}
+
+// buildBody builds the request body from whichever body option is set. Only one of them can be set
+func (r *Requester) buildBody() (requestBody, error) {
+	options := 0
+	for _, set := range []bool{r.Body != "", r.Json != nil, len(r.Form) > 0, r.Multipart != nil, r.BodyFile != ""} {
+		if set {
+			options++
+		}
+	}
+	if options > 1 {
+		return requestBody{}, errors.New("only one of body, json, form, multipart and bodyFile can be set")
+	}
+	switch {
+	case r.Json != nil:
+		data, err := json.Marshal(r.Json.Value)
+		return requestBody{data: data, contentType: "application/json"}, err
+	case len(r.Form) > 0:
+		form := url.Values{}
+		for name, value := range r.Form {
+			form.Set(name, value)
+		}
+		return requestBody{data: []byte(form.Encode()), contentType: "application/x-www-form-urlencoded"}, nil
+	case r.Multipart != nil:
+		return r.Multipart.encode()
+	case r.BodyFile != "":
+		data, err := os.ReadFile(r.BodyFile)
+		return requestBody{data: data, contentType: fileContentType(r.BodyFile)}, err
+	}
+	return requestBody{data: []byte(r.Body)}, nil
+}
+
+// encode encodes the fields and the files into a multipart/form-data body, in name order
+func (m *Multipart) encode() (requestBody, error) {
+	buffer := new(bytes.Buffer)
+	writer := multipart.NewWriter(buffer)
+	for _, name := range sortedKeys(m.Fields) {
+		if err := writer.WriteField(name, m.Fields[name]); err != nil {
+			return requestBody{}, err
+		}
+	}
+	for _, name := range sortedKeys(m.Files) {
+		data, err := os.ReadFile(m.Files[name])
+		if err != nil {
+			return requestBody{}, err
+		}
+		header := textproto.MIMEHeader{}
+		header.Set("Content-Disposition", fmt.Sprintf(`form-data; name=%q; filename=%q`, name,
+			filepath.Base(m.Files[name])))
+		header.Set("Content-Type", fileContentType(m.Files[name]))
+		part, err := writer.CreatePart(header)
+		if err != nil {
+			return requestBody{}, err
+		}
+		if _, err := part.Write(data); err != nil {
+			return requestBody{}, err
+		}
+	}
+	if err := writer.Close(); err != nil {
+		return requestBody{}, err
+	}
+	return requestBody{data: buffer.Bytes(), contentType: writer.FormDataContentType()}, nil
+}
+
+// fileContentType guesses the content type of the file from its extension
+func fileContentType(path string) string {
+	if contentType := mime.TypeByExtension(filepath.Ext(path)); contentType != "" {
+		return contentType
+	}
+	return "application/octet-stream"
+}
+
+// sortedKeys returns the keys of the map, sorted
+func sortedKeys(values map[string]string) []string {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
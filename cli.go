@@ -8,22 +8,33 @@ import (
 	"os"
 	"runtime"
 	"strconv"
+	"strings"
+	"time"
 )
 
-// printToCli will print the outcomes to CLI in the selected format
-func printToCli(outcomes []Outcome, format string) {
+// printToCli will print the outcomes to CLI in the selected format. The outcomes are grouped by probe, as returned by
+// the runs of each requester
+func printToCli(runs [][]Outcome, format string) {
+	outcomes := make([]Outcome, 0)
+	for _, run := range runs {
+		outcomes = append(outcomes, run...)
+	}
 	switch format {
 	case "console":
 		for index, outcome := range outcomes {
 			tablePrintOutcomeToCLI(outcome)
 			if index < len(outcomes)-1 {
-				width, _, _ := term.GetSize(0)
-				for i := 0; i < width-14; i++ {
+				for i := 0; i < terminalWidth()-14; i++ {
 					fmt.Print("*")
 				}
 				fmt.Print("\n")
 			}
 		}
+		tablePrintKeepAliveToCLI(runs)
+		tablePrintRowsToCLI(runs)
+	case "compact":
+		tablePrintWaterfallToCLI(outcomes)
+		tablePrintRowsToCLI(runs)
 	case "json":
 		if len(outcomes) == 1 {
 			prettyPrintJsonToCLI(outcomes[0])
@@ -54,7 +65,7 @@ func prettyPrintHarToCLI(outcomes []Outcome) {
 func buildTable(header ...string) *tablewriter.Table {
 	table := tablewriter.NewWriter(os.Stdout)
 	table.SetAlignment(tablewriter.ALIGN_LEFT)
-	width, _, _ := term.GetSize(0)
+	width := terminalWidth()
 	table.SetColWidth(width/2 - 10)
 	table.SetColMinWidth(0, (width)/2-10)
 	table.SetColMinWidth(1, width/2-10)
@@ -82,6 +93,16 @@ func appendError(table *tablewriter.Table, label string, val string) {
 			{}})
 	}
 
+}
+func appendWarning(table *tablewriter.Table, label string, val string) {
+	if runtime.GOOS == "windows" {
+		table.Append([]string{label, val})
+	} else {
+		table.Rich([]string{label, val}, []tablewriter.Colors{
+			{tablewriter.Normal, tablewriter.FgHiYellowColor},
+			{}})
+	}
+
 }
 func appendSuccess(table *tablewriter.Table, label string, val string) {
 	if runtime.GOOS == "windows" {
@@ -96,29 +117,79 @@ func appendSuccess(table *tablewriter.Table, label string, val string) {
 
 func tablePrintOutcomeToCLI(outcome Outcome) {
 	table := buildTable("Request", "Values")
+	if outcome.Requester.Name != "" {
+		table.Append([]string{"Name", outcome.Requester.Name})
+	}
+	if outcome.Requester.Description != "" {
+		table.Append([]string{"Description", outcome.Requester.Description})
+	}
+	if len(outcome.Requester.Tags) > 0 {
+		table.Append([]string{"Tags", strings.Join(outcome.Requester.Tags, ", ")})
+	}
 	table.Append([]string{"Method", outcome.Requester.Method})
 	table.Append([]string{"URL", outcome.Requester.Url})
 	table.Append([]string{"Timeout", outcome.Requester.Timeout.String()})
+	if outcome.Requester.Environment != "" {
+		table.Append([]string{"Environment", outcome.Requester.Environment})
+	}
+	if outcome.Requester.RowIndex > 0 {
+		table.Append([]string{"Row", rowLabel(outcome.Requester.RowIndex, outcome.Requester.Row)})
+	}
 	table.Render()
 	table = buildTable("Response", "Values")
 	table.Append([]string{"IP Address", outcome.IpAddress})
+	table.Append([]string{"IP Family", outcome.IpFamily})
+	table.Append([]string{"Protocol", outcome.Protocol})
 	table.Append([]string{"Status", strconv.Itoa(outcome.StatusCode)})
 	table.Append([]string{"Size", byteCountDecimal(outcome.Size)})
+	if outcome.Requester.Repeat > 1 {
+		table.Append([]string{"Reused", strconv.FormatBool(outcome.Connection.Reused)})
+		if outcome.Connection.WasIdle {
+			table.Append([]string{"Idle Time", outcome.Connection.IdleTime.String()})
+		}
+	}
 	if outcome.Err != nil {
 		appendError(table, "Error", outcome.Err.Error())
+		appendError(table, "Error Kind", outcome.Err.Kind)
+		if outcome.Err.Phase != "" {
+			appendError(table, "Error Phase", outcome.Err.Phase)
+		}
 	}
 	table.Render()
 	table = buildTable("Metrics", "Values")
-	if len(outcome.Annotations) == 0 && len(outcome.Checks) == 0 {
+	if len(outcome.Events) == 0 && len(outcome.Annotations) == 0 && len(outcome.Checks) == 0 {
 		table.SetBorders(tablewriter.Border{Left: true, Right: true, Top: true, Bottom: true})
 	}
+	table.Append([]string{"Blocked", outcome.Metrics.Blocked.String()})
 	table.Append([]string{"DNS", outcome.Metrics.DNS.String()})
 	table.Append([]string{"Conn", outcome.Metrics.Conn.String()})
+	if outcome.Requester.Proxy != nil {
+		table.Append([]string{"Proxy", outcome.Metrics.Proxy.String()})
+	}
 	table.Append([]string{"TLS", outcome.Metrics.TLS.String()})
+	table.Append([]string{"Send", outcome.Metrics.Send.String()})
 	table.Append([]string{"TTFB", outcome.Metrics.TTFB.String()})
 	table.Append([]string{"Transfer", outcome.Metrics.Transfer.String()})
 	table.Append([]string{"RT", outcome.Metrics.RT.String()})
+	if outcome.Token != nil {
+		table.Append([]string{"Token", fmt.Sprintf("%s (cached: %t)", outcome.Token.Duration, outcome.Token.Cached)})
+	}
+	if outcome.Challenge != nil {
+		table.Append([]string{"Challenge RT", outcome.Challenge.RT.String()})
+	}
+	table.Append([]string{"Waterfall", waterfallBar(outcome.Metrics, phasesTotal(outcome.Metrics), terminalWidth()/2-10)})
+	table.Append([]string{"Legend", waterfallLegend()})
 	table.Render()
+	if len(outcome.Events) > 0 {
+		table = buildTable("Timeline", "Offset")
+		for _, event := range outcome.Events {
+			table.Append([]string{event.Name, timelineBar(event, outcome.Events[len(outcome.Events)-1].Offset)})
+		}
+		if len(outcome.Annotations) == 0 && len(outcome.Checks) == 0 {
+			table.SetBorders(tablewriter.Border{Left: true, Right: true, Top: true, Bottom: true})
+		}
+		table.Render()
+	}
 	if len(outcome.Annotations) > 0 {
 		table = buildTable("Annotations", "Values")
 		for _, annotation := range outcome.Annotations {
@@ -134,10 +205,15 @@ func tablePrintOutcomeToCLI(outcome Outcome) {
 		table = buildTable("Assertions", "Results")
 		for _, check := range outcome.Checks {
 			output := fmt.Sprint(check.Output)
-			if check.Success {
-				appendSuccess(table, check.Assertion, output)
-			} else {
-				appendError(table, check.Assertion, output)
+			switch {
+			case check.Success:
+				appendSuccess(table, check.label(), output)
+			case check.Severity == SeverityWarn:
+				appendWarning(table, check.label(), "warning: "+output)
+			case check.Severity == SeverityInfo:
+				table.Append([]string{check.label(), "info: " + output})
+			default:
+				appendError(table, check.label(), output)
 			}
 
 		}
@@ -146,6 +222,101 @@ func tablePrintOutcomeToCLI(outcome Outcome) {
 	}
 }
 
+// terminalWidth returns the width of the terminal, or a sensible default when the output is not a terminal
+func terminalWidth() int {
+	width, _, err := term.GetSize(0)
+	if err != nil || width <= 0 {
+		return 120
+	}
+	return width
+}
+
+// timelineBar renders the offset of an event, followed by a marker positioned proportionally to the total duration
+func timelineBar(event Event, total time.Duration) string {
+	offset := fmt.Sprintf("%-12s", event.Offset.Round(time.Microsecond).String())
+	size := terminalWidth()/2 - 10 - len(offset) - 2
+	if size <= 0 || total <= 0 {
+		return offset
+	}
+	position := int(float64(size-1) * float64(event.Offset) / float64(total))
+	return offset + " " + strings.Repeat("·", position) + "●"
+}
+
+// tablePrintKeepAliveToCLI prints, for each probe whose calls have been repeated over a shared transport, the average
+// round-trip time of cold and warm connections
+func tablePrintKeepAliveToCLI(runs [][]Outcome) {
+	table := buildTable("Keep-alive", "Values")
+	rows := 0
+	for _, run := range runs {
+		cold, warm, ok := keepAliveBreakdown(run)
+		if !ok {
+			continue
+		}
+		label := fmt.Sprintf("%s %s", run[0].Requester.Method, run[0].Requester.Url)
+		if run[0].Requester.Name != "" {
+			label = run[0].Requester.Name
+		}
+		table.Append([]string{label, fmt.Sprintf("Cold RT %s, Warm RT %s, Saving %s", cold, warm, cold-warm)})
+		rows++
+	}
+	if rows == 0 {
+		return
+	}
+	table.SetBorders(tablewriter.Border{Left: true, Right: true, Top: true, Bottom: true})
+	table.Render()
+}
+
+// tablePrintRowsToCLI prints, for each dataset row, how many of its calls passed
+func tablePrintRowsToCLI(runs [][]Outcome) {
+	groups := rowGroups(runs)
+	if len(groups) == 0 {
+		return
+	}
+	table := buildTable("Dataset row", "Passed")
+	for _, group := range groups {
+		label := rowLabel(group[0].Requester.RowIndex, group[0].Requester.Row)
+		if group[0].Requester.documentName != "" {
+			label = fmt.Sprintf("%s %s", group[0].Requester.documentName, label)
+		}
+		passed := 0
+		for _, outcome := range group {
+			if outcome.isSuccess() {
+				passed++
+			}
+		}
+		if value := fmt.Sprintf("%d/%d", passed, len(group)); passed == len(group) {
+			appendSuccess(table, label, value)
+		} else {
+			appendError(table, label, value)
+		}
+	}
+	table.SetBorders(tablewriter.Border{Left: true, Right: true, Top: true, Bottom: true})
+	table.Render()
+}
+
+// keepAliveBreakdown computes the average round-trip time of the calls of a probe on fresh connections and on reused
+// connections. The last return value is false when the outcomes don't contain both
+func keepAliveBreakdown(outcomes []Outcome) (time.Duration, time.Duration, bool) {
+	var cold, warm time.Duration
+	coldCount, warmCount := 0, 0
+	for _, outcome := range outcomes {
+		if outcome.Err != nil {
+			continue
+		}
+		if outcome.Connection.Reused {
+			warm += outcome.Metrics.RT
+			warmCount++
+		} else {
+			cold += outcome.Metrics.RT
+			coldCount++
+		}
+	}
+	if coldCount == 0 || warmCount == 0 {
+		return 0, 0, false
+	}
+	return cold / time.Duration(coldCount), warm / time.Duration(warmCount), true
+}
+
 // byteCountDecimal will make the payload size human-readable
 func byteCountDecimal(b int) string {
 	const unit = 1000
@@ -0,0 +1,545 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"gopkg.in/yaml.v2"
+	yamlv3 "gopkg.in/yaml.v3"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ConfigError is an error in a configuration file, along with its location. The line is 0 when unknown
+type ConfigError struct {
+	Path     string
+	Document int
+	Line     int
+	Err      error
+}
+
+// Error returns the error message, prefixed by the location
+func (e *ConfigError) Error() string {
+	location := e.Path
+	if e.Document > 0 {
+		location += fmt.Sprintf(", document %d", e.Document)
+	}
+	if e.Line > 0 {
+		location += fmt.Sprintf(", line %d", e.Line)
+	}
+	return fmt.Sprintf("%s: %s", location, e.Err.Error())
+}
+
+// Unwrap returns the wrapped error
+func (e *ConfigError) Unwrap() error {
+	return e.Err
+}
+
+// configDocument is a configuration document, along with its location and the source tree of its values
+type configDocument struct {
+	values map[interface{}]interface{}
+	source *sourceNode
+	path   string
+	index  int
+}
+
+// error wraps the error into a ConfigError pointing at the document
+func (d configDocument) error(err error) error {
+	return &ConfigError{Path: d.path, Document: d.index, Err: err}
+}
+
+// prefixErrors prefixes the messages of the errors, joined or not, keeping their locations
+func prefixErrors(err error, prefix string) error {
+	errs := splitErrors(err)
+	for i, e := range errs {
+		var configErr *ConfigError
+		if errors.As(e, &configErr) {
+			errs[i] = &ConfigError{Path: configErr.Path, Document: configErr.Document, Line: configErr.Line,
+				Err: fmt.Errorf("%s: %w", prefix, configErr.Err)}
+		} else {
+			errs[i] = fmt.Errorf("%s: %w", prefix, e)
+		}
+	}
+	return joinErrors(errs)
+}
+
+// joinErrors joins the errors, returning nil when there are none and the error itself when there's only one
+func joinErrors(errs []error) error {
+	if len(errs) == 1 {
+		return errs[0]
+	}
+	return errors.Join(errs...)
+}
+
+// splitErrors returns the errors joined into the error, or the error itself
+func splitErrors(err error) []error {
+	if err == nil {
+		return nil
+	}
+	if joined, ok := err.(interface{ Unwrap() []error }); ok {
+		return joined.Unwrap()
+	}
+	return []error{err}
+}
+
+// loadConfig loads the requesters from the configuration file, see configLoader. Assertions and annotations get
+// compiled, once per source within each document, so that all the compile errors are reported at load time
+func loadConfig(path string, baseUrl string, environment string) ([]Requester, error) {
+	documents, err := loadDocuments(path, []string{})
+	if err != nil {
+		return nil, err
+	}
+	loader := newConfigLoader(baseUrl, environment)
+	requesters := make([]Requester, 0)
+	errs := make([]error, 0)
+	for _, document := range documents {
+		loaded, err := loader.load(document)
+		if err != nil {
+			return nil, err
+		}
+		cache := programCache{}
+		for i := range loaded {
+			for _, failed := range loaded[i].compile(cache) {
+				errs = append(errs, loaded[i].source.at(failed.kind+"s", failed.index).error(failed.error()))
+			}
+		}
+		requesters = append(requesters, loaded...)
+	}
+	if err := joinErrors(uniqueErrors(errs)); err != nil {
+		return nil, err
+	}
+	if err := loader.checkEnvironment(path); err != nil {
+		return nil, err
+	}
+	return requesters, nil
+}
+
+// configLoader loads the requesters from the documents, in order. A document made of a `defaults` key sets the
+// defaults merged into the following documents. A document made of an `environments` key defines the environments,
+// and the settings of the selected one are merged into the following documents, on top of the defaults. A document
+// with a `dataset` key is expanded into one requester per row of the dataset, with the values of the row replacing the
+// ${NAME} placeholders, and a document with a `matrix` key is expanded into one requester per combination, in the same
+// way. A base URL set in a document applies to the following documents too, unless overridden by the provided base URL.
+// The source trees of the defaults and of the settings are kept, so that the values they provide are located in the
+// document defining them
+type configLoader struct {
+	baseUrl          string
+	environment      string
+	defaults         map[interface{}]interface{}
+	defaultsSource   *sourceNode
+	settings         map[interface{}]interface{}
+	settingsSource   *sourceNode
+	variables        map[string]string
+	environmentFound bool
+	documentBaseUrl  string
+}
+
+// newConfigLoader is the constructor for configLoader
+func newConfigLoader(baseUrl string, environment string) *configLoader {
+	return &configLoader{baseUrl: baseUrl, environment: environment, defaults: map[interface{}]interface{}{},
+		settings: map[interface{}]interface{}{}, variables: map[string]string{}, environmentFound: environment == ""}
+}
+
+// load returns the requesters of the document. Defaults and environments documents produce no requesters. The errors
+// are returned along with the requesters that could be decoded anyway, so that they can still be validated
+func (l *configLoader) load(document configDocument) ([]Requester, error) {
+	if value, ok := document.values["defaults"]; ok {
+		if len(document.values) > 1 {
+			return nil, document.error(errors.New("a defaults document can't have other keys"))
+		}
+		defaults, ok := value.(map[interface{}]interface{})
+		if !ok {
+			return nil, document.error(errors.New("defaults must be a map"))
+		}
+		l.defaults, l.defaultsSource = defaults, document.source.key("defaults")
+		return nil, nil
+	}
+	if value, ok := document.values["environments"]; ok {
+		if len(document.values) > 1 {
+			return nil, document.error(errors.New("an environments document can't have other keys"))
+		}
+		settings, variables, err := environmentSettings(value, l.environment)
+		if err != nil {
+			return nil, document.error(err)
+		}
+		l.settings, l.variables, l.environmentFound = settings, variables, true
+		l.settingsSource = document.source.at("environments", l.environment)
+		return nil, nil
+	}
+	defaults, defaultsSource := mergeDefaults(l.defaults, l.settings, l.defaultsSource, l.settingsSource)
+	values, source := mergeDefaults(defaults, document.values, defaultsSource, document.source)
+	rows, err := documentRows(values, filepath.Dir(document.path))
+	if err != nil {
+		return nil, document.error(err)
+	}
+	combinations, err := documentCombinations(values)
+	if err != nil {
+		return nil, document.error(err)
+	}
+	requesters := make([]Requester, 0)
+	errs := make([]error, 0)
+	for index, row := range rows {
+		for _, combination := range combinations {
+			req, decoded, err := l.decode(values, source, row, combination)
+			if err != nil {
+				if combination != nil {
+					err = prefixErrors(err, valuesLabel(combination))
+				}
+				if row != nil {
+					err = prefixErrors(err, fmt.Sprintf("row %d", index+1))
+				}
+				errs = append(errs, err)
+			}
+			if !decoded {
+				continue
+			}
+			if row != nil {
+				req.Row = row
+				req.RowIndex = index + 1
+			}
+			if combination != nil {
+				req.Matrix = combination
+				req.Name = combinationName(req.Name, combination)
+			}
+			requesters = append(requesters, req)
+		}
+	}
+	return requesters, joinErrors(errs)
+}
+
+// decode decodes the requester from the document values, interpolating the variables of the environment, of the row
+// and of the combination. The errors point at the source of the values. The returned flag is true when the requester
+// got decoded, even with errors
+func (l *configLoader) decode(values map[interface{}]interface{}, source *sourceNode, row map[string]interface{},
+	combination map[string]interface{}) (Requester, bool, error) {
+	variables := mergeVariables(mergeVariables(l.variables, row), combination)
+	req, decoded, err := decodeRequester(interpolateVariables(values, variables), source, l.documentBaseUrl)
+	if !decoded {
+		return req, false, err
+	}
+	l.documentBaseUrl = req.BaseUrl
+	if l.baseUrl != "" {
+		req.BaseUrl = l.baseUrl
+	}
+	req.Environment = l.environment
+	req.documentName = req.Name
+	req.source = source
+	req.resolvePaths(source)
+	if composeErr := req.composeUrl(); composeErr != nil {
+		err = joinErrors(append(splitErrors(err), source.withoutLine().error(composeErr)))
+	}
+	return req, true, err
+}
+
+// checkEnvironment verifies the selected environment was defined
+func (l *configLoader) checkEnvironment(path string) error {
+	if !l.environmentFound {
+		return &ConfigError{Path: path, Err: fmt.Errorf("no environments defined for environment %s", l.environment)}
+	}
+	return nil
+}
+
+// decodeRequester decodes the requester from the document values, rejecting unknown keys. The base URL carries over
+// from the previous document. The errors point at the source of the values. On type errors, such as unknown keys, the
+// other values still get decoded, and the returned flag is true as the requester can still be validated
+func decodeRequester(values interface{}, source *sourceNode, baseUrl string) (Requester, bool, error) {
+	data, err := yaml.Marshal(values)
+	if err != nil {
+		return Requester{}, false, source.withoutLine().error(err)
+	}
+	req := newRequester("GET", "", make(map[string]string), make([]byte, 0), Duration{5 * time.Second}, false,
+		[]string{}, []string{})
+	req.BaseUrl = baseUrl
+	if err := yaml.UnmarshalStrict(data, &req); err != nil {
+		var typeErr *yaml.TypeError
+		return req, errors.As(err, &typeErr), source.locateErrors(err, data)
+	}
+	return req, true, nil
+}
+
+// environmentSettings returns the settings and the variables of the selected environment. With no environment
+// selected, no settings apply
+func environmentSettings(value interface{}, environment string) (map[interface{}]interface{}, map[string]string,
+	error) {
+	environments, ok := value.(map[interface{}]interface{})
+	if !ok {
+		return nil, nil, errors.New("environments must be a map")
+	}
+	if environment == "" {
+		return map[interface{}]interface{}{}, map[string]string{}, nil
+	}
+	selected, ok := environments[environment]
+	if !ok {
+		names := make([]string, 0)
+		for name := range environments {
+			names = append(names, fmt.Sprint(name))
+		}
+		sort.Strings(names)
+		return nil, nil, fmt.Errorf("unknown environment %s, either '%s'", environment, strings.Join(names, "', '"))
+	}
+	selectedMap, ok := selected.(map[interface{}]interface{})
+	if !ok && selected != nil {
+		return nil, nil, fmt.Errorf("environment %s must be a map", environment)
+	}
+	settings := map[interface{}]interface{}{}
+	variables := map[string]string{}
+	for key, value := range selectedMap {
+		if key != "variables" {
+			settings[key] = value
+			continue
+		}
+		variableMap, ok := value.(map[interface{}]interface{})
+		if !ok {
+			return nil, nil, fmt.Errorf("variables of environment %s must be a map", environment)
+		}
+		for name, variable := range variableMap {
+			variables[fmt.Sprint(name)] = fmt.Sprint(variable)
+		}
+	}
+	return settings, variables, nil
+}
+
+// loadDocuments reads the documents of the configuration file, resolving the includes. The stack of the files being
+// loaded is used to detect include cycles
+func loadDocuments(path string, stack []string) ([]configDocument, error) {
+	absolutePath, err := checkIncludeCycle(path, stack)
+	if err != nil {
+		return nil, err
+	}
+	stack = append(stack, absolutePath)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, &ConfigError{Path: path, Err: err}
+	}
+	decoder := yamlv3.NewDecoder(bytes.NewReader(data))
+	documents := make([]configDocument, 0)
+	for index := 1; ; index++ {
+		document := configDocument{path: path, index: index}
+		var node yamlv3.Node
+		if err := decoder.Decode(&node); err != nil {
+			if errors.Is(err, io.EOF) {
+				return documents, nil
+			}
+			return nil, document.error(err)
+		}
+		value, source, err := decodeSource(&node, path, index)
+		if err != nil {
+			return nil, document.error(err)
+		}
+		if value == nil {
+			continue
+		}
+		resolved, source, err := resolveListIncludes(value, source, filepath.Dir(path), stack)
+		if err != nil {
+			return nil, document.error(err)
+		}
+		values, ok := resolved.(map[interface{}]interface{})
+		if !ok {
+			return nil, document.error(errors.New("a document must be a map"))
+		}
+		document.values, document.source = values, source
+		value, ok = document.values["include"]
+		if !ok {
+			documents = append(documents, document)
+			continue
+		}
+		if len(document.values) > 1 {
+			return nil, document.error(errors.New("an include document can't have other keys"))
+		}
+		paths, err := includePaths(value)
+		if err != nil {
+			return nil, document.error(err)
+		}
+		for _, includePath := range paths {
+			included, err := loadDocuments(relativePath(filepath.Dir(path), includePath), stack)
+			if err != nil {
+				return nil, document.error(err)
+			}
+			documents = append(documents, included...)
+		}
+	}
+}
+
+// resolveListIncludes replaces the list items in the form `include: path` with the items of the list in the included
+// file, at any depth of the value. The source tree of the value is updated accordingly
+func resolveListIncludes(value interface{}, source *sourceNode, dir string, stack []string) (interface{}, *sourceNode,
+	error) {
+	switch v := value.(type) {
+	case map[interface{}]interface{}:
+		resolvedSource := source.withKeys()
+		for key, item := range v {
+			resolved, itemSource, err := resolveListIncludes(item, source.key(fmt.Sprint(key)), dir, stack)
+			if err != nil {
+				return nil, nil, err
+			}
+			v[key] = resolved
+			if itemSource != nil {
+				resolvedSource.keys[fmt.Sprint(key)] = itemSource
+			}
+		}
+		return v, resolvedSource, nil
+	case []interface{}:
+		resolved := make([]interface{}, 0)
+		resolvedSource := &sourceNode{}
+		if source != nil {
+			resolvedSource.path, resolvedSource.document, resolvedSource.line = source.path, source.document, source.line
+		}
+		for i, item := range v {
+			if itemMap, ok := item.(map[interface{}]interface{}); ok && len(itemMap) == 1 && itemMap["include"] != nil {
+				paths, err := includePaths(itemMap["include"])
+				if err != nil {
+					return nil, nil, err
+				}
+				for _, includePath := range paths {
+					items, itemsSource, err := loadList(relativePath(dir, includePath), stack)
+					if err != nil {
+						return nil, nil, err
+					}
+					resolved = append(resolved, items...)
+					resolvedSource.items = append(resolvedSource.items, itemsSource.items...)
+				}
+				continue
+			}
+			resolvedItem, itemSource, err := resolveListIncludes(item, source.item(i), dir, stack)
+			if err != nil {
+				return nil, nil, err
+			}
+			resolved = append(resolved, resolvedItem)
+			resolvedSource.items = append(resolvedSource.items, itemSource)
+		}
+		return resolved, resolvedSource, nil
+	}
+	return value, source, nil
+}
+
+// loadList reads the list in the included file, resolving its own includes
+func loadList(path string, stack []string) ([]interface{}, *sourceNode, error) {
+	absolutePath, err := checkIncludeCycle(path, stack)
+	if err != nil {
+		return nil, nil, err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, nil, &ConfigError{Path: path, Err: err}
+	}
+	var node yamlv3.Node
+	if err := yamlv3.Unmarshal(data, &node); err != nil {
+		return nil, nil, &ConfigError{Path: path, Err: err}
+	}
+	value, source, err := decodeSource(&node, path, 0)
+	if err != nil {
+		return nil, nil, &ConfigError{Path: path, Err: err}
+	}
+	items, ok := value.([]interface{})
+	if !ok && value != nil {
+		return nil, nil, &ConfigError{Path: path, Err: errors.New("an included list must be a list")}
+	}
+	resolved, source, err := resolveListIncludes(items, source, filepath.Dir(path), append(stack, absolutePath))
+	if err != nil {
+		return nil, nil, err
+	}
+	return resolved.([]interface{}), source, nil
+}
+
+// checkIncludeCycle returns the absolute path of the file, or an error if the file is already in the stack of the files
+// being loaded
+func checkIncludeCycle(path string, stack []string) (string, error) {
+	absolutePath, err := filepath.Abs(path)
+	if err != nil {
+		return "", &ConfigError{Path: path, Err: err}
+	}
+	for _, included := range stack {
+		if included == absolutePath {
+			return "", &ConfigError{Path: path, Err: fmt.Errorf("include cycle %s -> %s", strings.Join(stack, " -> "),
+				absolutePath)}
+		}
+	}
+	return absolutePath, nil
+}
+
+// relativePath resolves the path relative to the directory, unless it's absolute
+func relativePath(dir string, path string) string {
+	if filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(dir, path)
+}
+
+// sourcePath resolves the path relative to the directory of the configuration file the value is set in, unless it's
+// absolute
+func sourcePath(source *sourceNode, path string) string {
+	if source == nil || source.path == "" {
+		return path
+	}
+	return relativePath(filepath.Dir(source.path), path)
+}
+
+// resolvePaths resolves the paths of the files the request body is read from, as the includes and the datasets are
+func (r *Requester) resolvePaths(source *sourceNode) {
+	if r.BodyFile != "" {
+		r.BodyFile = sourcePath(source.at("bodyFile"), r.BodyFile)
+	}
+	if r.Multipart != nil && len(r.Multipart.Files) > 0 {
+		files := make(map[string]string)
+		for name, path := range r.Multipart.Files {
+			files[name] = sourcePath(source.at("multipart", "files", name), path)
+		}
+		r.Multipart.Files = files
+	}
+}
+
+// includePaths returns the paths of an include directive, either a single path or a list of paths
+func includePaths(value interface{}) ([]string, error) {
+	switch v := value.(type) {
+	case string:
+		return []string{v}, nil
+	case []interface{}:
+		paths := make([]string, 0)
+		for _, item := range v {
+			path, ok := item.(string)
+			if !ok {
+				return nil, errors.New("include must be a path or a list of paths")
+			}
+			paths = append(paths, path)
+		}
+		return paths, nil
+	}
+	return nil, errors.New("include must be a path or a list of paths")
+}
+
+// mergeDefaults merges the defaults into the document values. Maps are merged, lists are concatenated with the
+// defaults coming first, and any other value set by the document overrides the default. The source trees of the
+// defaults and of the values are merged the same way
+func mergeDefaults(defaults map[interface{}]interface{}, values map[interface{}]interface{}, defaultsSource *sourceNode,
+	valuesSource *sourceNode) (map[interface{}]interface{}, *sourceNode) {
+	merged := map[interface{}]interface{}{}
+	mergedSource := valuesSource.withKeys()
+	for key, value := range defaults {
+		merged[key] = value
+		mergedSource.keys[fmt.Sprint(key)] = defaultsSource.key(fmt.Sprint(key))
+	}
+	for key, value := range values {
+		name := fmt.Sprint(key)
+		switch v := value.(type) {
+		case map[interface{}]interface{}:
+			if defaultMap, ok := merged[key].(map[interface{}]interface{}); ok {
+				merged[key], mergedSource.keys[name] = mergeDefaults(defaultMap, v, defaultsSource.key(name),
+					valuesSource.key(name))
+				continue
+			}
+		case []interface{}:
+			if defaultList, ok := merged[key].([]interface{}); ok {
+				merged[key] = append(append([]interface{}{}, defaultList...), v...)
+				mergedSource.keys[name] = defaultsSource.key(name).concat(valuesSource.key(name))
+				continue
+			}
+		}
+		merged[key] = value
+		mergedSource.keys[name] = valuesSource.key(name)
+	}
+	return merged, mergedSource
+}
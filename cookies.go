@@ -0,0 +1,99 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// CookieJar is the run-scoped cookie jar shared by the requesters that opt in. It keeps track of the cookies it
+// receives, so that they can be persisted to a file between invocations
+type CookieJar struct {
+	jar     *cookiejar.Jar
+	mutex   sync.Mutex
+	cookies map[string]storedCookie
+}
+
+// storedCookie is a cookie along with the URL that set it
+type storedCookie struct {
+	Url    string       `json:"url"`
+	Cookie *http.Cookie `json:"cookie"`
+}
+
+// cookieJar is the cookie jar of the run
+var cookieJar = newCookieJar()
+
+// newCookieJar is the constructor for CookieJar
+func newCookieJar() *CookieJar {
+	jar, _ := cookiejar.New(nil)
+	return &CookieJar{jar: jar, cookies: map[string]storedCookie{}}
+}
+
+// SetCookies stores the cookies received from the URL
+func (j *CookieJar) SetCookies(u *url.URL, cookies []*http.Cookie) {
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+	j.jar.SetCookies(u, cookies)
+	origin := (&url.URL{Scheme: u.Scheme, Host: u.Host, Path: u.Path}).String()
+	for _, cookie := range cookies {
+		stored := *cookie
+		if stored.MaxAge > 0 {
+			stored.Expires = time.Now().Add(time.Duration(stored.MaxAge) * time.Second)
+			stored.MaxAge = 0
+		}
+		key := strings.Join([]string{u.Hostname(), stored.Domain, stored.Path, stored.Name}, "|")
+		j.cookies[key] = storedCookie{Url: origin, Cookie: &stored}
+	}
+}
+
+// Cookies returns the cookies to send to the URL
+func (j *CookieJar) Cookies(u *url.URL) []*http.Cookie {
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+	return j.jar.Cookies(u)
+}
+
+// load loads the cookies persisted in the file. A missing file is not an error, as it will be created on save
+func (j *CookieJar) load(path string) error {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	cookies := make([]storedCookie, 0)
+	if err := json.Unmarshal(data, &cookies); err != nil {
+		return err
+	}
+	for _, stored := range cookies {
+		if u, err := url.Parse(stored.Url); err == nil && stored.Cookie != nil {
+			j.SetCookies(u, []*http.Cookie{stored.Cookie})
+		}
+	}
+	return nil
+}
+
+// save persists the cookies to the file, leaving out the expired ones
+func (j *CookieJar) save(path string) error {
+	j.mutex.Lock()
+	cookies := make([]storedCookie, 0)
+	for _, stored := range j.cookies {
+		if stored.Cookie.MaxAge < 0 || (!stored.Cookie.Expires.IsZero() && stored.Cookie.Expires.Before(time.Now())) {
+			continue
+		}
+		cookies = append(cookies, stored)
+	}
+	j.mutex.Unlock()
+	data, err := json.MarshalIndent(cookies, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0600)
+}
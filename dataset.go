@@ -0,0 +1,160 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// documentRows returns the rows of the dataset the document points at, removing the dataset key from the values. A
+// document with no dataset produces a single nil row
+func documentRows(values map[interface{}]interface{}, dir string) ([]map[string]interface{}, error) {
+	value, ok := values["dataset"]
+	if !ok {
+		return []map[string]interface{}{nil}, nil
+	}
+	delete(values, "dataset")
+	path, ok := value.(string)
+	if !ok {
+		return nil, errors.New("dataset must be a path")
+	}
+	rows, err := loadDataset(relativePath(dir, path))
+	if err != nil {
+		return nil, err
+	}
+	if len(rows) == 0 {
+		return nil, fmt.Errorf("dataset %s has no rows", path)
+	}
+	return rows, nil
+}
+
+// loadDataset loads the rows of a CSV, JSON or JSONL dataset, depending on the file extension. CSV files need a header
+// line naming the columns, JSON files need to contain an array of objects, and JSONL files one object per line
+func loadDataset(path string) ([]map[string]interface{}, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	rows := make([]map[string]interface{}, 0)
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".csv":
+		records, err := csv.NewReader(bytes.NewReader(data)).ReadAll()
+		if err != nil {
+			return nil, err
+		}
+		for _, record := range records[min(1, len(records)):] {
+			row := map[string]interface{}{}
+			for i, column := range records[0] {
+				row[column] = record[i]
+			}
+			rows = append(rows, row)
+		}
+	case ".json":
+		if err := decodeJson(data, &rows); err != nil {
+			return nil, err
+		}
+	case ".jsonl", ".ndjson":
+		scanner := bufio.NewScanner(bytes.NewReader(data))
+		scanner.Buffer(make([]byte, 64*1024), len(data)+1)
+		for line := 1; scanner.Scan(); line++ {
+			if strings.TrimSpace(scanner.Text()) == "" {
+				continue
+			}
+			row := map[string]interface{}{}
+			if err := decodeJson(scanner.Bytes(), &row); err != nil {
+				return nil, fmt.Errorf("line %d: %w", line, err)
+			}
+			rows = append(rows, row)
+		}
+		if err := scanner.Err(); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("unsupported dataset %s, either CSV, JSON or JSONL", path)
+	}
+	for i := range rows {
+		rows[i] = jsonNumbers(rows[i]).(map[string]interface{})
+	}
+	return rows, nil
+}
+
+// decodeJson decodes the JSON data into the value, keeping the numbers as json.Number so that large integers, such as
+// IDs, don't lose precision or get formatted in exponent notation
+func decodeJson(data []byte, value interface{}) error {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	if err := decoder.Decode(value); err != nil {
+		return err
+	}
+	if _, err := decoder.Token(); err != io.EOF {
+		return errors.New("invalid character after top-level value")
+	}
+	return nil
+}
+
+// jsonNumbers turns the json.Number values into integers, or into floats when they're not integers
+func jsonNumbers(value interface{}) interface{} {
+	switch v := value.(type) {
+	case json.Number:
+		if integer, err := v.Int64(); err == nil {
+			return int(integer)
+		}
+		float, _ := v.Float64()
+		return float
+	case map[string]interface{}:
+		for key, item := range v {
+			v[key] = jsonNumbers(item)
+		}
+	case []interface{}:
+		for i, item := range v {
+			v[i] = jsonNumbers(item)
+		}
+	}
+	return value
+}
+
+// rowGroups groups the outcomes of the runs by dataset row, joining the runs of the matrix combinations of each row.
+// Runs of documents without a dataset are left out
+func rowGroups(runs [][]Outcome) [][]Outcome {
+	groups := make([][]Outcome, 0)
+	for _, run := range runs {
+		if len(run) == 0 || run[0].Requester.RowIndex == 0 {
+			continue
+		}
+		last := len(groups) - 1
+		if last >= 0 && groups[last][0].Requester.source == run[0].Requester.source &&
+			groups[last][0].Requester.RowIndex == run[0].Requester.RowIndex {
+			groups[last] = append(groups[last], run...)
+		} else {
+			groups = append(groups, append([]Outcome{}, run...))
+		}
+	}
+	return groups
+}
+
+// rowLabel describes the row, as in "#3 region=eu, tenant=acme"
+func rowLabel(index int, row map[string]interface{}) string {
+	return fmt.Sprintf("#%d %s", index, valuesLabel(row))
+}
+
+// valuesLabel describes the values in name order, as in "region=eu, tenant=acme"
+func valuesLabel(values map[string]interface{}) string {
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	pairs := make([]string, 0, len(names))
+	for _, name := range names {
+		pairs = append(pairs, fmt.Sprintf("%s=%v", name, values[name]))
+	}
+	return strings.Join(pairs, ", ")
+}
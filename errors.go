@@ -0,0 +1,137 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"syscall"
+)
+
+// The stable kinds errors get classified into
+const (
+	ErrorKindConfig           = "invalid_config"
+	ErrorKindAuth             = "auth_error"
+	ErrorKindDnsNxDomain      = "dns_nxdomain"
+	ErrorKindDnsTimeout       = "dns_timeout"
+	ErrorKindDns              = "dns_error"
+	ErrorKindConnectRefused   = "connect_refused"
+	ErrorKindConnectTimeout   = "connect_timeout"
+	ErrorKindConnect          = "connect_error"
+	ErrorKindTlsTimeout       = "tls_timeout"
+	ErrorKindTlsHandshake     = "tls_handshake"
+	ErrorKindTlsCertInvalid   = "tls_cert_invalid"
+	ErrorKindTlsCertExpired   = "tls_cert_expired"
+	ErrorKindReadTimeout      = "read_timeout"
+	ErrorKindBodyTruncated    = "body_truncated"
+	ErrorKindTooManyRedirects = "too_many_redirects"
+	ErrorKindTimeout          = "timeout"
+	ErrorKindUnknown          = "unknown"
+)
+
+// exitCodes maps each error kind to the exit code of the process. Failed assertions exit with 1
+var exitCodes = map[string]int{
+	ErrorKindConfig:           2,
+	ErrorKindUnknown:          3,
+	ErrorKindAuth:             4,
+	ErrorKindDnsNxDomain:      10,
+	ErrorKindDnsTimeout:       11,
+	ErrorKindDns:              12,
+	ErrorKindConnectRefused:   20,
+	ErrorKindConnectTimeout:   21,
+	ErrorKindConnect:          22,
+	ErrorKindTlsTimeout:       30,
+	ErrorKindTlsHandshake:     31,
+	ErrorKindTlsCertInvalid:   32,
+	ErrorKindTlsCertExpired:   33,
+	ErrorKindReadTimeout:      40,
+	ErrorKindBodyTruncated:    41,
+	ErrorKindTooManyRedirects: 50,
+	ErrorKindTimeout:          60,
+}
+
+// maxRedirects is the number of redirects the client will follow before giving up
+const maxRedirects = 10
+
+// errTooManyRedirects is returned when the client gives up following redirects
+var errTooManyRedirects = fmt.Errorf("stopped after %d redirects", maxRedirects)
+
+// checkRedirect is the redirect policy of the client, so that too many redirects can be told apart from other errors
+func checkRedirect(req *http.Request, via []*http.Request) error {
+	if len(via) >= maxRedirects {
+		return errTooManyRedirects
+	}
+	return nil
+}
+
+// classifyError classifies an error that occurred during the given phase into one of the error kinds
+func classifyError(err error, phase string) string {
+	var dnsErr *net.DNSError
+	var certInvalidErr x509.CertificateInvalidError
+	var unknownAuthorityErr x509.UnknownAuthorityError
+	var hostnameErr x509.HostnameError
+	var certVerificationErr *tls.CertificateVerificationError
+	switch {
+	case errors.Is(err, errTooManyRedirects):
+		return ErrorKindTooManyRedirects
+	case errors.As(err, &dnsErr):
+		if dnsErr.IsNotFound {
+			return ErrorKindDnsNxDomain
+		}
+		if dnsErr.IsTimeout {
+			return ErrorKindDnsTimeout
+		}
+		return ErrorKindDns
+	case errors.As(err, &certInvalidErr):
+		if certInvalidErr.Reason == x509.Expired {
+			return ErrorKindTlsCertExpired
+		}
+		return ErrorKindTlsCertInvalid
+	case errors.As(err, &unknownAuthorityErr), errors.As(err, &hostnameErr), errors.As(err, &certVerificationErr):
+		return ErrorKindTlsCertInvalid
+	case errors.Is(err, syscall.ECONNREFUSED):
+		return ErrorKindConnectRefused
+	case isTimeout(err):
+		switch phase {
+		case "dns":
+			return ErrorKindDnsTimeout
+		case "connect":
+			return ErrorKindConnectTimeout
+		case "tls":
+			return ErrorKindTlsTimeout
+		case "send", "ttfb", "body":
+			return ErrorKindReadTimeout
+		}
+		return ErrorKindTimeout
+	case phase == "body" && errors.Is(err, io.ErrUnexpectedEOF):
+		return ErrorKindBodyTruncated
+	case phase == "tls":
+		return ErrorKindTlsHandshake
+	case phase == "connect":
+		return ErrorKindConnect
+	case phase == "dns":
+		return ErrorKindDns
+	}
+	return ErrorKindUnknown
+}
+
+// exitCode returns the exit code of the process for the outcomes. That's the code of the first error kind found, 1 if
+// there are no errors but assertions failed, or 0 if all outcomes are a success
+func exitCode(outcomes []Outcome) int {
+	code := 0
+	for _, outcome := range outcomes {
+		if outcome.Err != nil {
+			if kindCode, ok := exitCodes[outcome.Err.Kind]; ok {
+				return kindCode
+			}
+			return exitCodes[ErrorKindUnknown]
+		}
+		if !outcome.isSuccess() {
+			code = 1
+		}
+	}
+	return code
+}
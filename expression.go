@@ -0,0 +1,84 @@
+package main
+
+import (
+	"fmt"
+	"github.com/antonmedv/expr"
+	"github.com/antonmedv/expr/vm"
+)
+
+// expression is an assertion or an annotation, compiled once against the outcome environment
+type expression struct {
+	kind      string
+	index     int
+	source    string
+	program   *vm.Program
+	err       error
+	assertion Assertion
+}
+
+// error returns the compile error, naming the expression
+func (e expression) error() error {
+	return fmt.Errorf("invalid %s %q: %s", e.kind, e.source, e.err.Error())
+}
+
+// compiledProgram is the program compiled from an expression source, or the compile error
+type compiledProgram struct {
+	program *vm.Program
+	err     error
+}
+
+// programCache holds the programs compiled from each expression source, so that the expressions shared by the
+// requesters of a document, such as the ones expanded from a dataset or a matrix, get compiled once. A nil cache
+// compiles the source every time
+type programCache map[string]compiledProgram
+
+// compile returns the program compiled from the source against the outcome environment
+func (c programCache) compile(source string) (*vm.Program, error) {
+	if compiled, ok := c[source]; ok {
+		return compiled.program, compiled.err
+	}
+	program, err := expr.Compile(source, expr.Env(expressionEnv(&Outcome{})))
+	if c != nil {
+		c[source] = compiledProgram{program: program, err: err}
+	}
+	return program, err
+}
+
+// compileExpressions compiles the expressions of the given kind. Expressions failing to compile keep their error
+func compileExpressions(kind string, sources []string, cache programCache) []expression {
+	expressions := make([]expression, 0, len(sources))
+	for i, source := range sources {
+		program, err := cache.compile(source)
+		expressions = append(expressions, expression{kind: kind, index: i, source: source, program: program,
+			err: err})
+	}
+	return expressions
+}
+
+// compileAssertions compiles the expressions of the assertions, keeping track of the assertion each one belongs to
+func compileAssertions(assertions []Assertion, cache programCache) []expression {
+	sources := make([]string, 0, len(assertions))
+	for _, assertion := range assertions {
+		sources = append(sources, assertion.Expr)
+	}
+	expressions := compileExpressions("assertion", sources, cache)
+	for i := range expressions {
+		expressions[i].assertion = assertions[i]
+	}
+	return expressions
+}
+
+// compile compiles the assertions and the annotations of the requester once, so that they don't get compiled on
+// every execution, reusing the programs of the cache. Returns the expressions failing to compile
+func (r *Requester) compile(cache programCache) []expression {
+	r.compiledAssertions = compileAssertions(r.Assertions, cache)
+	r.compiledAnnotations = compileExpressions("annotation", r.Annotations, cache)
+	r.compiled = true
+	failed := make([]expression, 0)
+	for _, compiled := range append(append([]expression{}, r.compiledAssertions...), r.compiledAnnotations...) {
+		if compiled.err != nil {
+			failed = append(failed, compiled)
+		}
+	}
+	return failed
+}
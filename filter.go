@@ -0,0 +1,42 @@
+package main
+
+// filterRequesters returns the requesters selected by the filters. With names, only the requesters having one of the
+// names are selected, matching either their own name or the name of the document they were generated from. With tags,
+// only the requesters having at least one of the tags are selected. Requesters having any of the skipped tags are
+// never selected
+func filterRequesters(requesters []Requester, names []string, tags []string, skipTags []string) []Requester {
+	selected := make([]Requester, 0)
+	for _, requester := range requesters {
+		if len(names) > 0 && !contains(names, requester.Name) && !contains(names, requester.documentName) {
+			continue
+		}
+		if len(tags) > 0 && !containsAny(requester.Tags, tags) {
+			continue
+		}
+		if containsAny(requester.Tags, skipTags) {
+			continue
+		}
+		selected = append(selected, requester)
+	}
+	return selected
+}
+
+// contains returns true when the value is in the list
+func contains(list []string, value string) bool {
+	for _, item := range list {
+		if item == value && value != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// containsAny returns true when any of the values is in the list
+func containsAny(list []string, values []string) bool {
+	for _, value := range values {
+		if contains(list, value) {
+			return true
+		}
+	}
+	return false
+}
@@ -1,16 +1,23 @@
 module redProbe
 
-go 1.17
+go 1.24
 
 require (
 	github.com/antonmedv/expr v1.9.0
 	github.com/olekukonko/tablewriter v0.0.5
 	github.com/pborman/getopt/v2 v2.1.0
-	golang.org/x/term v0.0.0-20210927222741-03fcf44c2211
+	github.com/quic-go/quic-go v0.58.0
+	golang.org/x/term v0.34.0
 	gopkg.in/yaml.v2 v2.4.0
+	gopkg.in/yaml.v3 v3.0.1
 )
 
 require (
+	github.com/kr/text v0.2.0 // indirect
 	github.com/mattn/go-runewidth v0.0.9 // indirect
-	golang.org/x/sys v0.0.0-20210615035016-665e8c7367d1 // indirect
+	github.com/quic-go/qpack v0.6.0 // indirect
+	golang.org/x/crypto v0.41.0 // indirect
+	golang.org/x/net v0.43.0 // indirect
+	golang.org/x/sys v0.35.0 // indirect
+	golang.org/x/text v0.28.0 // indirect
 )
@@ -3,6 +3,7 @@ package main
 import (
 	"net/http"
 	"net/url"
+	"path/filepath"
 	"time"
 )
 
@@ -26,12 +27,17 @@ type Creator struct {
 
 // Entry is one entry in the HAR file
 type Entry struct {
-	StartedDateTime time.Time     `json:"startedDateTime"`
-	Time            int           `json:"time"`
-	Request         EntryRequest  `json:"request"`
-	Response        EntryResponse `json:"response"`
-	Cache           interface{}   `json:"cache"`
-	Timings         Timings       `json:"timings"`
+	StartedDateTime time.Time              `json:"startedDateTime"`
+	Time            int                    `json:"time"`
+	Request         EntryRequest           `json:"request"`
+	Response        EntryResponse          `json:"response"`
+	Cache           interface{}            `json:"cache"`
+	Timings         Timings                `json:"timings"`
+	Comment         string                 `json:"comment,omitempty"`
+	Description     string                 `json:"_description,omitempty"`
+	Tags            []string               `json:"_tags,omitempty"`
+	Environment     string                 `json:"_environment,omitempty"`
+	Row             map[string]interface{} `json:"_row,omitempty"`
 }
 
 // Timings is the recorded timings for a HAR entry
@@ -83,8 +89,17 @@ func NewEntryCookie(cookie *http.Cookie) EntryCookie {
 
 // EntryPostData is the container of post data for the HAR file
 type EntryPostData struct {
-	MimeType string `json:"mimeType"`
-	Text     string `json:"text"`
+	MimeType string       `json:"mimeType"`
+	Text     string       `json:"text"`
+	Params   []EntryParam `json:"params"`
+}
+
+// EntryParam is a posted parameter for the HAR file, either a form field or a file
+type EntryParam struct {
+	Name        string `json:"name"`
+	Value       string `json:"value,omitempty"`
+	FileName    string `json:"fileName,omitempty"`
+	ContentType string `json:"contentType,omitempty"`
 }
 
 // EntryResponse is the response definition for an HAR file entry
@@ -113,10 +128,11 @@ func toHar(outcomes []Outcome) Har {
 	log.Version = "1.2"
 	log.Entries = []Entry{}
 	for _, o := range outcomes {
-		entry := Entry{StartedDateTime: o.StartTime}
+		entry := Entry{StartedDateTime: o.StartTime, Comment: o.Requester.Name, Description: o.Requester.Description,
+			Tags: o.Requester.Tags, Environment: o.Requester.Environment, Row: o.Requester.Row}
 		entry.Time = int(o.Metrics.RT.Seconds())
 		request := EntryRequest{Method: o.Requester.Method, URL: o.Requester.Url}
-		request.HttpVersion = "HTTP/2.0"
+		request.HttpVersion = o.Protocol
 		request.HeadersSize = -1
 		request.Cookies = make([]interface{}, 0)
 		request.Headers = make([]EntryPair, 0)
@@ -128,17 +144,20 @@ func toHar(outcomes []Outcome) Har {
 		for k, v := range parsedUrl.Query() {
 			request.QueryString = append(request.QueryString, EntryPair{Name: k, Value: v[0]})
 		}
-		if len(o.Requester.Body) > 0 {
+		if len(o.sentBody.data) > 0 {
 			request.PostData = &EntryPostData{}
-			request.PostData.Text = o.Requester.Body
-			request.PostData.MimeType = o.Requester.getContentType()
-			entry.Request.BodySize = len(o.Requester.Body)
+			request.PostData.MimeType = o.sentBody.contentType
+			request.PostData.Params = postDataParams(o.Requester)
+			if o.Requester.Multipart == nil {
+				request.PostData.Text = string(o.sentBody.data)
+			}
+			request.BodySize = len(o.sentBody.data)
 		}
 		entry.Request = request
 
 		response := EntryResponse{}
 		response.HeadersSize = -1
-		response.HttpVersion = o.httpVersion
+		response.HttpVersion = o.Protocol
 		response.Status = o.StatusCode
 		response.StatusText = o.statusText
 		response.Headers = make([]EntryPair, 0)
@@ -162,10 +181,30 @@ func toHar(outcomes []Outcome) Har {
 
 		entry.Response = response
 		entry.Cache = map[string]interface{}{}
-		entry.Timings = Timings{Send: 0, Connect: int(o.Metrics.Conn.Milliseconds()),
-			Receive: int(o.Metrics.Transfer.Milliseconds()), Blocked: 0, SSL: int(o.Metrics.TLS.Milliseconds()),
-			Wait: int(o.Metrics.TTFB.Milliseconds()), DNS: int(o.Metrics.DNS.Milliseconds())}
+		entry.Timings = Timings{Send: int(o.Metrics.Send.Milliseconds()), Connect: int(o.Metrics.Conn.Milliseconds()),
+			Receive: int(o.Metrics.Transfer.Milliseconds()), Blocked: int(o.Metrics.Blocked.Milliseconds()),
+			SSL: int(o.Metrics.TLS.Milliseconds()), Wait: int(o.Metrics.TTFB.Milliseconds()),
+			DNS: int(o.Metrics.DNS.Milliseconds())}
 		log.Entries = append(log.Entries, entry)
 	}
 	return Har{Log: log}
 }
+
+// postDataParams returns the posted parameters of form and multipart bodies
+func postDataParams(requester Requester) []EntryParam {
+	params := make([]EntryParam, 0)
+	for _, name := range sortedKeys(requester.Form) {
+		params = append(params, EntryParam{Name: name, Value: requester.Form[name]})
+	}
+	if requester.Multipart != nil {
+		for _, name := range sortedKeys(requester.Multipart.Fields) {
+			params = append(params, EntryParam{Name: name, Value: requester.Multipart.Fields[name]})
+		}
+		for _, name := range sortedKeys(requester.Multipart.Files) {
+			path := requester.Multipart.Files[name]
+			params = append(params, EntryParam{Name: name, FileName: filepath.Base(path),
+				ContentType: fileContentType(path)})
+		}
+	}
+	return params
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptrace"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/quic-go/quic-go"
+	"github.com/quic-go/quic-go/http3"
+)
+
+// Http3Transport is the HTTP/3 round tripper. It wraps the QUIC transport so that the UDP sockets it opens get
+// closed with it. As the QUIC transport doesn't report the connections to the tracer, the IP address each authority
+// got dialed on is recorded
+type Http3Transport struct {
+	*http3.Transport
+	requester *Requester
+	conns     []net.PacketConn
+	addresses map[string]string
+	mutex     sync.Mutex
+}
+
+// newHttp3Transport is the constructor for Http3Transport
+func newHttp3Transport(requester *Requester) *Http3Transport {
+	transport := Http3Transport{requester: requester, Transport: &http3.Transport{}, addresses: map[string]string{}}
+	transport.Transport.Dial = transport.dial
+	if requester.SkipSSL {
+		transport.TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
+	}
+	return &transport
+}
+
+// dial establishes the QUIC connection, honouring the IP address, the IP version and the timeouts of the requester.
+// The addresses the host resolves to are tried in order
+func (t *Http3Transport) dial(ctx context.Context, addr string, tlsConfig *tls.Config,
+	config *quic.Config) (*quic.Conn, error) {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+	host = t.requester.pinnedHost(host)
+	portNumber, err := strconv.Atoi(port)
+	if err != nil {
+		return nil, err
+	}
+	ips, err := t.requester.lookup(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+	var conn *quic.Conn
+	for _, ip := range ips {
+		if conn, err = t.dialAddress(ctx, ip, portNumber, tlsConfig, config); err == nil {
+			t.mutex.Lock()
+			t.addresses[addr] = ip
+			t.mutex.Unlock()
+			break
+		}
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	return conn, err
+}
+
+// dialAddress establishes the QUIC connection to the IP address. As QUIC merges the connection and the TLS handshake,
+// the whole handshake gets traced as the connection phase
+func (t *Http3Transport) dialAddress(ctx context.Context, ip string, port int, tlsConfig *tls.Config,
+	config *quic.Config) (*quic.Conn, error) {
+	udpConn, err := net.ListenUDP(t.requester.network("udp"), nil)
+	if err != nil {
+		return nil, err
+	}
+	t.conns = append(t.conns, udpConn)
+	udpAddr := &net.UDPAddr{IP: net.ParseIP(ip), Port: port}
+	trace := httptrace.ContextClientTrace(ctx)
+	if trace != nil && trace.ConnectStart != nil {
+		trace.ConnectStart("udp", udpAddr.String())
+	}
+	if t.requester.ConnectTimeout.Duration > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, t.requester.ConnectTimeout.Duration)
+		defer cancel()
+	}
+	conn, err := quic.Dial(ctx, udpConn, udpAddr, tlsConfig, config)
+	if trace != nil && trace.ConnectDone != nil {
+		trace.ConnectDone("udp", udpAddr.String(), err)
+	}
+	return conn, err
+}
+
+// remoteAddress returns the IP address the QUIC connection to the URL authority got dialed on, if any
+func (t *Http3Transport) remoteAddress(requestUrl *url.URL) string {
+	port := requestUrl.Port()
+	if port == "" {
+		port = "443"
+	}
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	return t.addresses[net.JoinHostPort(requestUrl.Hostname(), port)]
+}
+
+// Close closes the QUIC connections and the UDP sockets
+func (t *Http3Transport) Close() error {
+	err := t.Transport.Close()
+	for _, conn := range t.conns {
+		_ = conn.Close()
+	}
+	return err
+}
+
+// validateHttp3 verifies the requester settings are compatible with HTTP/3
+func (r *Requester) validateHttp3() error {
+	if r.Proxy != nil {
+		return errors.New("proxies are not supported over HTTP/3")
+	}
+	if !strings.HasPrefix(strings.ToLower(r.Url), "https://") {
+		return errors.New("HTTP/3 requires an HTTPS URL")
+	}
+	return nil
+}
+
+// http3Advertised returns true when the response headers advertise HTTP/3 availability via Alt-Svc
+func http3Advertised(header http.Header) bool {
+	for _, altSvc := range header.Values("Alt-Svc") {
+		for _, service := range strings.Split(altSvc, ",") {
+			if strings.HasPrefix(strings.TrimSpace(service), "h3") {
+				return true
+			}
+		}
+	}
+	return false
+}
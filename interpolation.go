@@ -0,0 +1,61 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+)
+
+// variablePattern matches the ${NAME} placeholders
+var variablePattern = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_.]*)}`)
+
+// interpolate replaces the ${NAME} placeholders in the value with the environment variable of the same name.
+// Placeholders referring to unset variables are left untouched
+func interpolate(value string) string {
+	return variablePattern.ReplaceAllStringFunc(value, func(placeholder string) string {
+		name := variablePattern.FindStringSubmatch(placeholder)[1]
+		if variable, ok := os.LookupEnv(name); ok {
+			return variable
+		}
+		return placeholder
+	})
+}
+
+// interpolateVariables replaces the ${NAME} placeholders in all strings of the value with the variable of the same
+// name. Placeholders referring to other variables are left untouched, to be interpolated from the environment later
+func interpolateVariables(value interface{}, variables map[string]string) interface{} {
+	switch v := value.(type) {
+	case string:
+		return variablePattern.ReplaceAllStringFunc(v, func(placeholder string) string {
+			if variable, ok := variables[variablePattern.FindStringSubmatch(placeholder)[1]]; ok {
+				return variable
+			}
+			return placeholder
+		})
+	case map[interface{}]interface{}:
+		interpolated := map[interface{}]interface{}{}
+		for key, item := range v {
+			interpolated[key] = interpolateVariables(item, variables)
+		}
+		return interpolated
+	case []interface{}:
+		interpolated := make([]interface{}, len(v))
+		for i, item := range v {
+			interpolated[i] = interpolateVariables(item, variables)
+		}
+		return interpolated
+	}
+	return value
+}
+
+// mergeVariables returns the variables merged with the values, which take precedence
+func mergeVariables(variables map[string]string, values map[string]interface{}) map[string]string {
+	merged := map[string]string{}
+	for name, value := range variables {
+		merged[name] = value
+	}
+	for name, value := range values {
+		merged[name] = fmt.Sprint(value)
+	}
+	return merged
+}
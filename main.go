@@ -2,10 +2,8 @@ package main
 
 import (
 	"bufio"
-	"bytes"
 	"fmt"
 	"github.com/pborman/getopt/v2"
-	"gopkg.in/yaml.v2"
 	"io/ioutil"
 	"os"
 	"strings"
@@ -18,34 +16,74 @@ func main() {
 	url := getopt.StringLong("url", 'u', "", "The URL")
 	headers := getopt.ListLong("header", 'H', "The headers")
 	timeout := getopt.StringLong("timeout", 't', "5s", "The request timeout")
-	format := getopt.StringLong("format", 'f', "console", "The output format, either 'console', 'JSON' or 'HAR'")
+	format := getopt.StringLong("format", 'f', "console", "The output format, either 'console', 'compact', 'JSON' or 'HAR'")
 	assertions := getopt.ListLong("assertion", 'A', "Assertion")
 	annotations := getopt.ListLong("annotation", 'a', "Annotation")
 	config := getopt.StringLong("config", 'c', "", "Path to a config file")
 	skipSSL := getopt.BoolLong("skip-ssl", 's', "Skips SSL validation")
+	baseUrl := getopt.StringLong("base-url", 'b', "", "The base URL, overriding the configuration")
+	environment := getopt.StringLong("env", 'e', "", "The environment defined in the configuration to run against")
+	cookieJarPath := getopt.StringLong("cookie-jar", 'j', "", "Path to a file persisting the cookies between runs")
+	only := getopt.ListLong("only", 'o', "Runs only the probes with the given names")
+	tags := getopt.ListLong("tags", 'T', "Runs only the probes with any of the given tags")
+	skipTags := getopt.ListLong("skip-tags", 'S', "Skips the probes with any of the given tags")
 	getopt.HelpColumn = 50
-	getopt.Parse()
+	getopt.SetParameters("[validate]")
+	validate := len(os.Args) > 1 && os.Args[1] == "validate"
+	if validate {
+		getopt.CommandLine.Parse(append([]string{os.Args[0]}, os.Args[2:]...))
+	} else {
+		getopt.Parse()
+		validate = getopt.NArgs() > 0 && getopt.Arg(0) == "validate"
+	}
+	if *baseUrl == "" {
+		*baseUrl = os.Getenv("REDPROBE_BASE_URL")
+	}
+	if validate {
+		if *config == "" {
+			getopt.PrintUsage(os.Stdout)
+			os.Exit(exitCodes[ErrorKindConfig])
+		}
+		os.Exit(runValidate(*config, *baseUrl, *environment))
+	}
 	requesters := make([]Requester, 0)
 	if *config != "" {
-		requesters = requesterFromConfig(*config)
+		requesters = requesterFromConfig(*config, *baseUrl, *environment)
 	} else {
-		requesters = append(requesters, requesterFromCli(*method, *url, *headers, readBody(), *timeout, *skipSSL, *assertions, *annotations))
+		requester := requesterFromCli(*method, *url, *baseUrl, *headers, readBody(), *timeout, *skipSSL, *assertions,
+			*annotations)
+		requester.Cookies = *cookieJarPath != ""
+		requesters = append(requesters, requester)
+	}
+	requesters = filterRequesters(requesters, *only, *tags, *skipTags)
+	if len(requesters) == 0 {
+		fmt.Println("No probes match the filters")
+		os.Exit(exitCodes[ErrorKindConfig])
+	}
+	if *cookieJarPath != "" {
+		if err := cookieJar.load(*cookieJarPath); err != nil {
+			fmt.Println("Error reading the cookie jar: ", err.Error())
+			os.Exit(1)
+		}
 	}
 	outcomes := make([]Outcome, 0)
+	runs := make([][]Outcome, 0)
 	if format != nil {
 		*format = strings.ToLower(*format)
 	}
 	for _, requester := range requesters {
 		requester.keepResponse = *format == "har"
-		outcome := requester.run()
-		outcomes = append(outcomes, outcome)
+		run := requester.runAll()
+		runs = append(runs, run)
+		outcomes = append(outcomes, run...)
 	}
-	printToCli(outcomes, *format)
-	for _, outcome := range outcomes {
-		if !outcome.isSuccess() {
-			os.Exit(1)
+	if *cookieJarPath != "" {
+		if err := cookieJar.save(*cookieJarPath); err != nil {
+			fmt.Println("Error writing the cookie jar: ", err.Error())
 		}
 	}
+	printToCli(runs, *format)
+	os.Exit(exitCode(outcomes))
 }
 
 // readBody reads the request body from the standard input, if there's any
@@ -60,43 +98,41 @@ func readBody() []byte {
 }
 
 // requesterFromConfig runs the CLI probe pulling the settings from a configuration file
-func requesterFromConfig(path string) []Requester {
-	data, err := ioutil.ReadFile(path)
+func requesterFromConfig(path string, baseUrl string, environment string) []Requester {
+	requesters, err := loadConfig(path, baseUrl, environment)
 	if err != nil {
-		fmt.Println("Error reading the configuration file: ", err.Error())
-		os.Exit(1)
-	}
-	decoder := yaml.NewDecoder(bytes.NewReader(data))
-	requesters := make([]Requester, 0)
-	for err == nil {
-		req := newRequester("GET", "", make(map[string]string), make([]byte, 0), Duration{5 * time.Second}, false, []string{}, []string{})
-		err = decoder.Decode(&req)
-		if err != nil {
-			break
-		}
-		requesters = append(requesters, req)
-	}
-	if err.Error() != "EOF" {
 		fmt.Println("Error reading configuration file: ", err.Error())
-		os.Exit(1)
+		os.Exit(exitCodes[ErrorKindConfig])
 	}
 	return requesters
 }
 
 // requesterFromCli runs the command line probe using the parameters passed in the command line
-func requesterFromCli(method string, urlString string, headers []string, body []byte, timeout string, skipSSL bool,
-	assertions []string, annotations []string) Requester {
-	if urlString == "" {
+func requesterFromCli(method string, urlString string, baseUrl string, headers []string, body []byte, timeout string,
+	skipSSL bool, assertions []string, annotations []string) Requester {
+	if urlString == "" && baseUrl == "" {
 		getopt.PrintUsage(os.Stdout)
-		os.Exit(1)
+		os.Exit(exitCodes[ErrorKindConfig])
 	}
 	d, err := time.ParseDuration(timeout)
 	if err != nil {
 		fmt.Println("Could not parse timeout")
-		os.Exit(1)
+		os.Exit(exitCodes[ErrorKindConfig])
 	}
-	return newRequester(strings.ToUpper(method), urlString, arrayToMap(headers), body, Duration{d}, skipSSL,
+	requester := newRequester(strings.ToUpper(method), urlString, arrayToMap(headers), body, Duration{d}, skipSSL,
 		assertions, annotations)
+	requester.BaseUrl = baseUrl
+	if err := requester.composeUrl(); err != nil {
+		fmt.Println("Could not compose the URL: ", err.Error())
+		os.Exit(exitCodes[ErrorKindConfig])
+	}
+	if failed := requester.compile(nil); len(failed) > 0 {
+		for _, expression := range failed {
+			fmt.Println("Could not compile the expressions: ", expression.error().Error())
+		}
+		os.Exit(exitCodes[ErrorKindConfig])
+	}
+	return requester
 }
 
 // arrayToMap turns an array of colon-separated strings into a map
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// documentCombinations returns the combinations of the matrix of the document, removing the matrix key from the
+// values. The matrix maps each dimension to its list of values, and the optional `exclude` list removes the
+// combinations matching all the values of any of its entries. A document with no matrix produces a single nil
+// combination
+func documentCombinations(values map[interface{}]interface{}) ([]map[string]interface{}, error) {
+	value, ok := values["matrix"]
+	if !ok {
+		return []map[string]interface{}{nil}, nil
+	}
+	delete(values, "matrix")
+	matrix, ok := value.(map[interface{}]interface{})
+	if !ok {
+		return nil, errors.New("matrix must be a map")
+	}
+	dimensions := make(map[string][]interface{})
+	excludes := make([]map[interface{}]interface{}, 0)
+	for key, items := range matrix {
+		if key == "exclude" {
+			list, ok := items.([]interface{})
+			if !ok {
+				return nil, errors.New("matrix exclude must be a list")
+			}
+			for _, item := range list {
+				exclude, ok := item.(map[interface{}]interface{})
+				if !ok {
+					return nil, errors.New("matrix exclude entries must be maps")
+				}
+				excludes = append(excludes, exclude)
+			}
+			continue
+		}
+		list, ok := items.([]interface{})
+		if !ok || len(list) == 0 {
+			return nil, fmt.Errorf("matrix dimension %v must be a non-empty list", key)
+		}
+		dimensions[fmt.Sprint(key)] = list
+	}
+	for _, exclude := range excludes {
+		for key := range exclude {
+			if _, ok := dimensions[fmt.Sprint(key)]; !ok {
+				return nil, fmt.Errorf("matrix exclude refers to unknown dimension %v", key)
+			}
+		}
+	}
+	names := make([]string, 0, len(dimensions))
+	for name := range dimensions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	combinations := []map[string]interface{}{{}}
+	for _, name := range names {
+		expanded := make([]map[string]interface{}, 0)
+		for _, combination := range combinations {
+			for _, item := range dimensions[name] {
+				next := map[string]interface{}{name: item}
+				for key, value := range combination {
+					next[key] = value
+				}
+				expanded = append(expanded, next)
+			}
+		}
+		combinations = expanded
+	}
+	included := make([]map[string]interface{}, 0)
+	for _, combination := range combinations {
+		if !isExcluded(combination, excludes) {
+			included = append(included, combination)
+		}
+	}
+	if len(included) == 0 {
+		return nil, errors.New("matrix excludes all combinations")
+	}
+	return included, nil
+}
+
+// isExcluded returns true when the combination matches all the values of any of the exclude entries
+func isExcluded(combination map[string]interface{}, excludes []map[interface{}]interface{}) bool {
+	for _, exclude := range excludes {
+		matches := true
+		for key, value := range exclude {
+			if fmt.Sprint(combination[fmt.Sprint(key)]) != fmt.Sprint(value) {
+				matches = false
+				break
+			}
+		}
+		if matches {
+			return true
+		}
+	}
+	return false
+}
+
+// combinationName names the requester after the combination, keeping the name of the document, if any
+func combinationName(name string, combination map[string]interface{}) string {
+	if name == "" {
+		return valuesLabel(combination)
+	}
+	return fmt.Sprintf("%s (%s)", name, valuesLabel(combination))
+}
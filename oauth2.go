@@ -0,0 +1,114 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// OAuth2 is the configuration of the OAuth2 client credentials flow. The token is fetched before the call and cached
+// for its lifetime. Secrets can refer to environment variables using the ${NAME} syntax
+type OAuth2 struct {
+	TokenUrl     string   `json:"tokenUrl" yaml:"tokenUrl"`
+	ClientId     string   `json:"clientId" yaml:"clientId"`
+	ClientSecret string   `json:"-" yaml:"clientSecret"`
+	Scopes       []string `json:"scopes" yaml:"scopes"`
+	Audience     string   `json:"audience,omitempty" yaml:"audience"`
+}
+
+// TokenAcquisition describes how the OAuth2 token was acquired
+type TokenAcquisition struct {
+	Duration time.Duration `json:"duration"`
+	Cached   bool          `json:"cached"`
+}
+
+// cachedToken is an OAuth2 token with its expiration
+type cachedToken struct {
+	authorization string
+	expiresAt     time.Time
+}
+
+// tokenCache caches the OAuth2 tokens for their lifetime, across all the calls of the run
+var tokenCache = struct {
+	sync.Mutex
+	tokens map[string]cachedToken
+}{tokens: map[string]cachedToken{}}
+
+// tokenExpirySkew is subtracted from the token lifetime, so that tokens about to expire don't get used
+const tokenExpirySkew = 10 * time.Second
+
+// cacheKey returns the key identifying the token in the cache. The client secret is part of it, hashed, so that a
+// changed secret doesn't get masked by a token fetched with the previous one
+func (o *OAuth2) cacheKey() string {
+	secret := hashHex([]byte(interpolate(o.ClientSecret)))
+	return strings.Join([]string{interpolate(o.TokenUrl), interpolate(o.ClientId), secret, strings.Join(o.Scopes, " "),
+		o.Audience}, "|")
+}
+
+// authorization returns the Authorization header value carrying the token, fetching the token unless a valid one is
+// cached. The token is requested through the provided transport
+func (o *OAuth2) authorization(timeout time.Duration, transport *http.Transport) (string, TokenAcquisition, error) {
+	start := time.Now()
+	key := o.cacheKey()
+	tokenCache.Lock()
+	defer tokenCache.Unlock()
+	if token, ok := tokenCache.tokens[key]; ok && time.Now().Before(token.expiresAt) {
+		return token.authorization, TokenAcquisition{Duration: time.Since(start), Cached: true}, nil
+	}
+	token, err := o.fetch(timeout, transport)
+	if err != nil {
+		return "", TokenAcquisition{Duration: time.Since(start)}, err
+	}
+	if !token.expiresAt.IsZero() {
+		tokenCache.tokens[key] = token
+	}
+	return token.authorization, TokenAcquisition{Duration: time.Since(start)}, nil
+}
+
+// fetch requests a new token to the token endpoint
+func (o *OAuth2) fetch(timeout time.Duration, transport *http.Transport) (cachedToken, error) {
+	form := url.Values{"grant_type": {"client_credentials"}}
+	if len(o.Scopes) > 0 {
+		form.Set("scope", strings.Join(o.Scopes, " "))
+	}
+	if o.Audience != "" {
+		form.Set("audience", o.Audience)
+	}
+	request, err := http.NewRequest("POST", interpolate(o.TokenUrl), strings.NewReader(form.Encode()))
+	if err != nil {
+		return cachedToken{}, err
+	}
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.Header.Set("Accept", "application/json")
+	request.SetBasicAuth(url.QueryEscape(interpolate(o.ClientId)), url.QueryEscape(interpolate(o.ClientSecret)))
+	defer transport.CloseIdleConnections()
+	client := http.Client{Timeout: timeout, Transport: transport}
+	res, err := client.Do(request)
+	if err != nil {
+		return cachedToken{}, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return cachedToken{}, fmt.Errorf("token endpoint responded with status %d", res.StatusCode)
+	}
+	var body struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int    `json:"expires_in"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&body); err != nil {
+		return cachedToken{}, err
+	}
+	if body.AccessToken == "" {
+		return cachedToken{}, errors.New("token endpoint responded with no access token")
+	}
+	token := cachedToken{authorization: "Bearer " + body.AccessToken}
+	if body.ExpiresIn > 0 {
+		token.expiresAt = time.Now().Add(time.Duration(body.ExpiresIn)*time.Second - tokenExpirySkew)
+	}
+	return token, nil
+}
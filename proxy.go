@@ -0,0 +1,39 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+)
+
+// Proxy is the configuration of the proxy the call goes through
+type Proxy struct {
+	Url             string `json:"url" yaml:"url"`
+	Username        string `json:"username" yaml:"username"`
+	Password        string `json:"-" yaml:"password"`
+	FromEnvironment bool   `json:"fromEnvironment" yaml:"fromEnvironment"`
+}
+
+// proxyFunc returns the function the transport will use to select the proxy for a request. Supported proxy URL
+// schemes are "http" and "https" (HTTP CONNECT for TLS targets) and "socks5"
+func (p *Proxy) proxyFunc() (func(*http.Request) (*url.URL, error), error) {
+	if p == nil {
+		return nil, nil
+	}
+	if p.FromEnvironment {
+		return http.ProxyFromEnvironment, nil
+	}
+	proxyUrl, err := url.Parse(p.Url)
+	if err != nil {
+		return nil, err
+	}
+	switch proxyUrl.Scheme {
+	case "http", "https", "socks5":
+	default:
+		return nil, errors.New("invalid proxy URL scheme, either 'http', 'https' or 'socks5'")
+	}
+	if p.Username != "" {
+		proxyUrl.User = url.UserPassword(p.Username, p.Password)
+	}
+	return http.ProxyURL(proxyUrl), nil
+}
@@ -2,60 +2,141 @@ package main
 
 import (
 	"crypto/tls"
+	"net"
 	"net/http"
 	"net/http/httptrace"
+	"net/textproto"
+	"sort"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 )
 
 // RedTracer will collect times of the events during an HTTP call
 type RedTracer struct {
-	clientTrace httptrace.ClientTrace
-	connStart   time.Time
-	connDone    time.Time
-	dnsStart    time.Time
-	dnsEnd      time.Time
-	tlsStart    time.Time
-	tlsEnd      time.Time
-	firstByte   time.Time
-	complete    time.Time
-	ipAddress   string
+	clientTrace  httptrace.ClientTrace
+	start        time.Time
+	getConn      time.Time
+	gotConn      time.Time
+	connStart    time.Time
+	connDone     time.Time
+	connected    bool
+	dnsStart     time.Time
+	dnsEnd       time.Time
+	tlsStart     time.Time
+	tlsEnd       time.Time
+	wroteRequest time.Time
+	firstByte    time.Time
+	complete     time.Time
+	ipAddress    string
+	proxied      bool
+	connection   Connection
+	events       []Event
+	mutex        sync.Mutex
+}
+
+// Event is an event traced during the call, with its offset from the beginning of the call
+type Event struct {
+	Name   string        `json:"name"`
+	Offset time.Duration `json:"offset"`
+	Detail string        `json:"detail,omitempty"`
+	Error  string        `json:"error,omitempty"`
 }
 
 // newRedTracer is the constructor for RedTracer
 func newRedTracer() *RedTracer {
 	rt := RedTracer{}
 	rt.clientTrace = httptrace.ClientTrace{
+		GetConn: func(hostPort string) {
+			rt.getConn = rt.event("GetConn", hostPort, nil)
+		},
 		DNSStart: func(info httptrace.DNSStartInfo) {
-			rt.dnsStart = time.Now()
+			rt.dnsStart = rt.event("DNSStart", info.Host, nil)
 		},
 		DNSDone: func(info httptrace.DNSDoneInfo) {
-			rt.dnsEnd = time.Now()
+			addresses := make([]string, 0)
+			for _, address := range info.Addrs {
+				addresses = append(addresses, address.String())
+			}
+			rt.dnsEnd = rt.event("DNSDone", strings.Join(addresses, ", "), info.Err)
 		},
 		ConnectStart: func(network, addr string) {
-			rt.connStart = time.Now()
+			now := rt.event("ConnectStart", addr, nil)
+			rt.mutex.Lock()
+			defer rt.mutex.Unlock()
+			if rt.connStart.IsZero() {
+				rt.connStart = now
+			}
 		},
 		GotConn: func(info httptrace.GotConnInfo) {
 			rt.ipAddress = info.Conn.RemoteAddr().String()
-			if strings.Contains(rt.ipAddress, ":") {
-				rt.ipAddress = strings.SplitN(rt.ipAddress, ":", 2)[0]
+			if host, _, err := net.SplitHostPort(rt.ipAddress); err == nil {
+				rt.ipAddress = host
 			}
+			rt.connection = Connection{Reused: info.Reused, WasIdle: info.WasIdle, IdleTime: info.IdleTime}
+			rt.gotConn = rt.event("GotConn", info.Conn.RemoteAddr().String(), nil)
 		},
 		ConnectDone: func(network, addr string, err error) {
-			rt.connDone = time.Now()
+			now := rt.event("ConnectDone", addr, err)
+			rt.mutex.Lock()
+			defer rt.mutex.Unlock()
+			if !rt.connected {
+				rt.connDone, rt.connected = now, err == nil
+			}
 		},
 		TLSHandshakeStart: func() {
-			rt.tlsStart = time.Now()
+			rt.tlsStart = rt.event("TLSHandshakeStart", "", nil)
 		},
 		TLSHandshakeDone: func(state tls.ConnectionState, err error) {
-			rt.tlsEnd = time.Now()
+			rt.tlsEnd = rt.event("TLSHandshakeDone", state.NegotiatedProtocol, err)
+		},
+		WroteHeaders: func() {
+			rt.event("WroteHeaders", "", nil)
+		},
+		WroteRequest: func(info httptrace.WroteRequestInfo) {
+			rt.wroteRequest = rt.event("WroteRequest", "", info.Err)
+		},
+		Got100Continue: func() {
+			rt.event("Got100Continue", "", nil)
+		},
+		Got1xxResponse: func(code int, header textproto.MIMEHeader) error {
+			rt.event("Got1xxResponse", strconv.Itoa(code), nil)
+			return nil
 		},
 		GotFirstResponseByte: func() {
-			rt.firstByte = time.Now()
+			rt.firstByte = rt.event("GotFirstResponseByte", "", nil)
 		}}
 	return &rt
 }
 
+// event records an event in the timeline and returns the time it happened. Events may be recorded concurrently, as
+// connection attempts to multiple addresses can happen in parallel: the connection phase spans from the first attempt
+// starting to the successful one completing, or to the last one failing
+func (rt *RedTracer) event(name string, detail string, err error) time.Time {
+	now := time.Now()
+	event := Event{Name: name, Offset: now.Sub(rt.start), Detail: detail}
+	if err != nil {
+		event.Error = err.Error()
+	}
+	rt.mutex.Lock()
+	rt.events = append(rt.events, event)
+	rt.mutex.Unlock()
+	return now
+}
+
+// timeline returns the recorded events, sorted by offset
+func (rt *RedTracer) timeline() []Event {
+	rt.mutex.Lock()
+	defer rt.mutex.Unlock()
+	events := make([]Event, len(rt.events))
+	copy(events, rt.events)
+	sort.SliceStable(events, func(i, j int) bool {
+		return events[i].Offset < events[j].Offset
+	})
+	return events
+}
+
 // addContext adds the tracer context to the request
 func (rt *RedTracer) addContext(req *http.Request) *http.Request {
 	ctx := httptrace.WithClientTrace(req.Context(), &rt.clientTrace)
@@ -65,7 +146,60 @@ func (rt *RedTracer) addContext(req *http.Request) *http.Request {
 // stop needs to be invoked at the end of the request, once the body has been pulled. This method records the end of
 // the conversation as there's no other way to know that
 func (rt *RedTracer) stop() {
-	rt.complete = time.Now()
+	rt.complete = rt.event("BodyComplete", "", nil)
+}
+
+// connectionPhase returns the beginning and the end of the connection phase. Losing connection attempts may still be
+// reporting while the call proceeds
+func (rt *RedTracer) connectionPhase() (time.Time, time.Time) {
+	rt.mutex.Lock()
+	defer rt.mutex.Unlock()
+	return rt.connStart, rt.connDone
+}
+
+// phase returns the phase the call was going through when it stopped, and the time the phase started
+func (rt *RedTracer) phase() (string, time.Time) {
+	connStart, _ := rt.connectionPhase()
+	switch {
+	case !rt.firstByte.IsZero():
+		return "body", rt.firstByte
+	case !rt.wroteRequest.IsZero():
+		return "ttfb", rt.wroteRequest
+	case !rt.gotConn.IsZero():
+		return "send", rt.gotConn
+	case !rt.tlsStart.IsZero():
+		return "tls", rt.tlsStart
+	case !connStart.IsZero():
+		return "connect", connStart
+	case !rt.dnsStart.IsZero():
+		return "dns", rt.dnsStart
+	}
+	return "blocked", rt.start
+}
+
+// blocked will return the time the call spent queued, waiting for a connection to be available. That's the time from
+// the connection request to the beginning of the DNS resolution or connection, or to the reused connection being
+// handed over
+func (rt *RedTracer) blocked() time.Duration {
+	if rt.getConn.IsZero() {
+		return 0
+	}
+	connStart, _ := rt.connectionPhase()
+	for _, next := range []time.Time{rt.dnsStart, connStart, rt.gotConn} {
+		if !next.IsZero() {
+			return positiveOrZero(next.Sub(rt.getConn))
+		}
+	}
+	return 0
+}
+
+// send will return the time spent writing the request, from the moment the connection is available to the request
+// being completely written
+func (rt *RedTracer) send() time.Duration {
+	if rt.wroteRequest.IsZero() {
+		return 0
+	}
+	return positiveOrZero(rt.wroteRequest.Sub(rt.gotConn))
 }
 
 // dns will return the DNS duration
@@ -75,7 +209,18 @@ func (rt *RedTracer) dns() time.Duration {
 
 // conn will return the time to connect duration
 func (rt *RedTracer) conn() time.Duration {
-	return rt.connDone.Sub(rt.connStart)
+	connStart, connDone := rt.connectionPhase()
+	return connDone.Sub(connStart)
+}
+
+// proxy will return the time spent establishing the tunnel through the proxy, from the moment the connection to the
+// proxy is established to the beginning of the upstream TLS handshake
+func (rt *RedTracer) proxy() time.Duration {
+	if !rt.proxied || rt.tlsStart.IsZero() {
+		return 0
+	}
+	_, connDone := rt.connectionPhase()
+	return positiveOrZero(rt.tlsStart.Sub(connDone))
 }
 
 // tls will return the TLS handshake duration
@@ -83,10 +228,19 @@ func (rt *RedTracer) tls() time.Duration {
 	return positiveOrZero(rt.tlsEnd.Sub(rt.tlsStart))
 }
 
-// ttfb will return the Time-To-First-Byte duration, which is the time from the complete handshake and the first byte
+// ttfb will return the Time-To-First-Byte duration, which is the time the server took to respond, from the request
+// being completely written to the first byte. When the request writing is not traced, the time is measured from the
+// complete handshake or, if the connection is reused, from the beginning of the call
 func (rt *RedTracer) ttfb() time.Duration {
+	if !rt.wroteRequest.IsZero() {
+		return positiveOrZero(rt.firstByte.Sub(rt.wroteRequest))
+	}
+	connStart, connDone := rt.connectionPhase()
+	if connStart.IsZero() {
+		return positiveOrZero(rt.firstByte.Sub(rt.start))
+	}
 	if rt.tlsStart.IsZero() {
-		return positiveOrZero(rt.firstByte.Sub(rt.connDone))
+		return positiveOrZero(rt.firstByte.Sub(connDone))
 	}
 	return positiveOrZero(rt.firstByte.Sub(rt.tlsEnd))
 }
@@ -96,9 +250,13 @@ func (rt *RedTracer) transfer() time.Duration {
 	return positiveOrZero(rt.complete.Sub(rt.firstByte))
 }
 
-// rt is the full round-trip time
+// rt is the full round-trip time. When the connection is reused, it's measured from the beginning of the call
 func (rt *RedTracer) rt() time.Duration {
-	return positiveOrZero(rt.complete.Sub(rt.connStart))
+	connStart, _ := rt.connectionPhase()
+	if connStart.IsZero() {
+		return positiveOrZero(rt.complete.Sub(rt.start))
+	}
+	return positiveOrZero(rt.complete.Sub(connStart))
 }
 
 // positiveOrZero will return the provided duration if it's greater than zero, or zero otherwise
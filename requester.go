@@ -2,14 +2,16 @@ package main
 
 import (
 	"bytes"
+	"context"
 	"crypto/tls"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"github.com/antonmedv/expr"
 	"io"
+	"net"
 	"net/http"
-	"strconv"
+	"net/url"
 	"strings"
 	"time"
 )
@@ -33,23 +35,31 @@ func (d *Duration) UnmarshalYAML(unmarshal func(interface{}) error) error {
 		var err error
 		d.Duration, err = time.ParseDuration(value)
 		if err != nil {
-			return err
+			return lineTypeError(unmarshal, fmt.Errorf("invalid duration %s: %w", value, err))
 		}
 		return nil
 	default:
-		return errors.New("invalid duration")
+		return lineTypeError(unmarshal, errors.New("invalid duration"))
 	}
 }
 
-// RedError is a wrapper for Error so that marshalling is easier and automatic
+// RedError is a wrapper for Error so that marshalling is easier and automatic. The error is classified into a Kind,
+// and comes with the Phase the call was going through when it failed
 type RedError struct {
-	Err error
+	Err   error
+	Kind  string
+	Phase string
+}
+
+// newRedError is the constructor for RedError. It classifies the error that occurred during the given phase
+func newRedError(err error, phase string) *RedError {
+	return &RedError{Err: err, Kind: classifyError(err, phase), Phase: phase}
 }
 
-// MarshalJSON will marshall the error message into JSON
+// MarshalJSON will marshall the error message into JSON. The kind and the phase are marshalled by the outcome
 func (e *RedError) MarshalJSON() (b []byte, err error) {
 	if e.Err == nil {
-		return nil, nil
+		return []byte("null"), nil
 	}
 	return json.Marshal(e.Err.Error())
 }
@@ -61,49 +71,117 @@ func (e *RedError) Error() string {
 
 // Requester is the agent performing the request
 type Requester struct {
-	Method       string            `json:"method" yaml:"method"`
-	Url          string            `json:"url" yaml:"url"`
-	Headers      map[string]string `json:"headers" yaml:"headers"`
-	Body         string            `json:"body" yaml:"body"`
-	Timeout      Duration          `json:"timeout" yaml:"timeout"`
-	Assertions   []string          `json:"assertions" yaml:"assertions"`
-	Annotations  []string          `json:"annotations" yaml:"annotations"`
-	SkipSSL      bool              `json:"skipSSL" yaml:"skipSSL"`
-	keepResponse bool
+	Name                string                 `json:"name" yaml:"name"`
+	Description         string                 `json:"description" yaml:"description"`
+	Tags                []string               `json:"tags" yaml:"tags"`
+	Method              string                 `json:"method" yaml:"method"`
+	Url                 string                 `json:"url" yaml:"url"`
+	BaseUrl             string                 `json:"baseUrl" yaml:"baseUrl"`
+	Path                string                 `json:"path" yaml:"path"`
+	Query               map[string]string      `json:"query" yaml:"query"`
+	Headers             map[string]string      `json:"headers" yaml:"headers"`
+	Body                string                 `json:"body" yaml:"body"`
+	Json                *JsonBody              `json:"json" yaml:"json"`
+	Form                map[string]string      `json:"form" yaml:"form"`
+	Multipart           *Multipart             `json:"multipart" yaml:"multipart"`
+	BodyFile            string                 `json:"bodyFile" yaml:"bodyFile"`
+	Timeout             Duration               `json:"timeout" yaml:"timeout"`
+	DnsTimeout          Duration               `json:"dnsTimeout" yaml:"dnsTimeout"`
+	ConnectTimeout      Duration               `json:"connectTimeout" yaml:"connectTimeout"`
+	TlsTimeout          Duration               `json:"tlsTimeout" yaml:"tlsTimeout"`
+	TtfbTimeout         Duration               `json:"ttfbTimeout" yaml:"ttfbTimeout"`
+	BodyTimeout         Duration               `json:"bodyTimeout" yaml:"bodyTimeout"`
+	Auth                *Auth                  `json:"auth" yaml:"auth"`
+	OAuth2              *OAuth2                `json:"oauth2" yaml:"oauth2"`
+	Signing             *Signing               `json:"signing" yaml:"signing"`
+	Assertions          []Assertion            `json:"assertions" yaml:"assertions"`
+	Annotations         []string               `json:"annotations" yaml:"annotations"`
+	SkipSSL             bool                   `json:"skipSSL" yaml:"skipSSL"`
+	AllAddresses        bool                   `json:"allAddresses" yaml:"allAddresses"`
+	IpVersion           string                 `json:"ipVersion" yaml:"ipVersion"`
+	Proxy               *Proxy                 `json:"proxy" yaml:"proxy"`
+	Protocol            string                 `json:"protocol" yaml:"protocol"`
+	Repeat              int                    `json:"repeat" yaml:"repeat"`
+	Cookies             bool                   `json:"cookies" yaml:"cookies"`
+	Environment         string                 `json:"environment" yaml:"-"`
+	Row                 map[string]interface{} `json:"row,omitempty" yaml:"-"`
+	RowIndex            int                    `json:"rowIndex,omitempty" yaml:"-"`
+	Matrix              map[string]interface{} `json:"matrix,omitempty" yaml:"-"`
+	keepResponse        bool
+	address             string
+	documentName        string
+	source              *sourceNode
+	compiled            bool
+	compiledAssertions  []expression
+	compiledAnnotations []expression
 }
 
 // Outcome is the result of the conversation
 type Outcome struct {
-	Requester   Requester    `json:"request"`
-	StartTime   time.Time    `json:"startTime"`
-	IpAddress   string       `json:"ip_address"`
-	StatusCode  int          `json:"statusCode"`
-	Size        int          `json:"size"`
-	Metrics     Metrics      `json:"metrics"`
-	Err         *RedError    `json:"error"`
-	Annotations []Annotation `json:"annotations"`
-	Checks      []Check      `json:"checks"`
-
-	bodyBytes   []byte
-	Header      http.Header `json:"-"`
-	httpVersion string
-	statusText  string
-	cookies     []*http.Cookie
-}
-
-// isSuccess will return true when no errors happened during the call, and all assertions passed
+	Requester       Requester         `json:"request"`
+	StartTime       time.Time         `json:"startTime"`
+	IpAddress       string            `json:"ip_address"`
+	IpFamily        string            `json:"ip_family"`
+	Protocol        string            `json:"protocol"`
+	Http3Advertised bool              `json:"http3Advertised"`
+	StatusCode      int               `json:"statusCode"`
+	Size            int               `json:"size"`
+	Metrics         Metrics           `json:"metrics"`
+	Challenge       *Metrics          `json:"challenge,omitempty"`
+	Token           *TokenAcquisition `json:"token,omitempty"`
+	Connection      Connection        `json:"connection"`
+	Events          []Event           `json:"events"`
+	Err             *RedError         `json:"error"`
+	Annotations     []Annotation      `json:"annotations"`
+	Checks          []Check           `json:"checks"`
+	Warnings        []string          `json:"warnings,omitempty"`
+
+	bodyBytes  []byte
+	Header     http.Header `json:"-"`
+	statusText string
+	cookies    []*http.Cookie
+	sentBody   requestBody
+}
+
+// MarshalJSON marshals the outcome, adding the kind and the phase of the error, if any, next to the error message
+func (o Outcome) MarshalJSON() ([]byte, error) {
+	type plain Outcome
+	view := struct {
+		plain
+		ErrorKind  string `json:"errorKind,omitempty"`
+		ErrorPhase string `json:"errorPhase,omitempty"`
+	}{plain: plain(o)}
+	if o.Err != nil {
+		view.ErrorKind = o.Err.Kind
+		view.ErrorPhase = o.Err.Phase
+	}
+	return json.Marshal(view)
+}
+
+// isSuccess will return true when no errors happened during the call, and all assertions passed. Failing assertions
+// with the info or warn severity don't count
 func (o *Outcome) isSuccess() bool {
 	if o.Err != nil {
 		return false
 	}
 	for _, check := range o.Checks {
-		if !check.Success {
+		if check.failed() {
 			return false
 		}
 	}
 	return true
 }
 
+// Cookie returns the cookie with the given name set by the response, or nil if there's none
+func (o *Outcome) Cookie(name string) *http.Cookie {
+	for _, cookie := range o.cookies {
+		if cookie.Name == name {
+			return cookie
+		}
+	}
+	return nil
+}
+
 // JsonMap assumes the response body is a JSON and converts it into a Map
 func (o *Outcome) JsonMap() map[string]interface{} {
 	intFace := make(map[string]interface{})
@@ -120,19 +198,31 @@ func (o *Outcome) JsonArray() []interface{} {
 
 // Metrics are the collected metrics
 type Metrics struct {
+	Blocked  time.Duration `json:"blocked"`
 	DNS      time.Duration `json:"DNS"`
 	Conn     time.Duration `json:"conn"`
+	Proxy    time.Duration `json:"proxy"`
 	TLS      time.Duration `json:"TLS"`
+	Send     time.Duration `json:"send"`
 	TTFB     time.Duration `json:"TTFB"`
 	Transfer time.Duration `json:"transfer"`
 	RT       time.Duration `json:"rt"`
 }
 
+// Connection describes the connection the call went through
+type Connection struct {
+	Reused   bool          `json:"reused"`
+	WasIdle  bool          `json:"wasIdle"`
+	IdleTime time.Duration `json:"idleTime"`
+}
+
 // Check is the result of an assertion execution
 type Check struct {
 	Success   bool        `json:"success"`
 	Output    interface{} `json:"output"`
 	Assertion string      `json:"assertion"`
+	Name      string      `json:"name,omitempty"`
+	Severity  string      `json:"severity"`
 }
 
 // Annotation is the result of an annotation execution
@@ -145,109 +235,467 @@ type Annotation struct {
 func newRequester(method string, url string, headers map[string]string, body []byte, timeout Duration, skipSSL bool,
 	assertions []string, annotations []string) Requester {
 	return Requester{Method: method, Url: url, Headers: headers, Body: string(body), Timeout: timeout, SkipSSL: skipSSL,
-		Assertions: assertions, Annotations: annotations}
+		Assertions: newAssertions(assertions), Annotations: annotations}
+}
+
+// composeUrl composes the final URL. When the URL is empty or relative, it gets appended to the base URL, then the
+// path gets appended, and the query parameters get merged in
+func (r *Requester) composeUrl() error {
+	if r.BaseUrl == "" && r.Path == "" && len(r.Query) == 0 {
+		return nil
+	}
+	target, err := url.Parse(r.Url)
+	if err != nil {
+		return err
+	}
+	if !target.IsAbs() && r.BaseUrl != "" {
+		base, err := url.Parse(r.BaseUrl)
+		if err != nil {
+			return err
+		}
+		if !base.IsAbs() {
+			return fmt.Errorf("base URL %s is not absolute", r.BaseUrl)
+		}
+		target = joinUrl(base, target)
+	}
+	if r.Path != "" {
+		path, err := url.Parse(r.Path)
+		if err != nil {
+			return err
+		}
+		target = joinUrl(target, path)
+	}
+	if len(r.Query) > 0 {
+		query := target.Query()
+		for _, name := range sortedKeys(r.Query) {
+			query.Set(name, r.Query[name])
+		}
+		target.RawQuery = query.Encode()
+	}
+	if !target.IsAbs() {
+		return fmt.Errorf("URL %s is not absolute, and no base URL is set", target.String())
+	}
+	r.Url = target.String()
+	return nil
+}
+
+// joinUrl appends the path of the relative URL to the path of the base URL, merging their query parameters
+func joinUrl(base *url.URL, relative *url.URL) *url.URL {
+	joined := base.JoinPath(relative.Path)
+	if relative.Path == "" {
+		joined.Path = base.Path
+		joined.RawPath = base.RawPath
+	}
+	query := base.Query()
+	for name, values := range relative.Query() {
+		query[name] = values
+	}
+	joined.RawQuery = query.Encode()
+	return joined
+}
+
+// runAll performs the call. When AllAddresses is set, the host gets resolved and the call is performed once per
+// resolved address, producing one outcome per address
+func (r *Requester) runAll() []Outcome {
+	switch r.IpVersion {
+	case "", "4", "6":
+	case "both":
+		outcomes := make([]Outcome, 0)
+		for _, version := range []string{"4", "6"} {
+			requester := *r
+			requester.IpVersion = version
+			outcomes = append(outcomes, requester.runAll()...)
+		}
+		return outcomes
+	default:
+		err := errors.New("invalid ipVersion, either '4', '6' or 'both'")
+		return []Outcome{{Requester: *r, StartTime: time.Now(), Err: &RedError{Err: err, Kind: ErrorKindConfig}}}
+	}
+	if !r.AllAddresses {
+		return r.runSequence()
+	}
+	addresses, err := r.resolve()
+	if err != nil {
+		return []Outcome{{Requester: *r, StartTime: time.Now(), Err: newRedError(err, "dns")}}
+	}
+	outcomes := make([]Outcome, 0)
+	for _, address := range addresses {
+		requester := *r
+		requester.address = address
+		outcomes = append(outcomes, requester.runSequence()...)
+	}
+	return outcomes
+}
+
+// resolve returns all the IP addresses the host of the URL resolves to, filtered by IP version
+func (r *Requester) resolve() ([]string, error) {
+	parsedUrl, err := url.Parse(r.Url)
+	if err != nil {
+		return nil, err
+	}
+	host := parsedUrl.Hostname()
+	ipAddresses := make([]net.IP, 0)
+	if ip := net.ParseIP(host); ip != nil {
+		ipAddresses = append(ipAddresses, ip)
+	} else {
+		ctx, cancel := context.WithTimeout(context.Background(), r.Timeout.Duration)
+		defer cancel()
+		resolved, err := net.DefaultResolver.LookupIP(ctx, r.network("ip"), host)
+		if err != nil {
+			return nil, err
+		}
+		ipAddresses = append(ipAddresses, resolved...)
+	}
+	addresses := make([]string, 0)
+	for _, ipAddress := range ipAddresses {
+		if r.IpVersion == "" || ipFamily(ipAddress.String()) == "IPv"+r.IpVersion {
+			addresses = append(addresses, ipAddress.String())
+		}
+	}
+	if len(addresses) == 0 {
+		return nil, fmt.Errorf("no IPv%s address found for %s", r.IpVersion, host)
+	}
+	return addresses, nil
+}
+
+// network returns the network to use, forcing the address family according to the IP version
+func (r *Requester) network(network string) string {
+	switch r.IpVersion {
+	case "4", "6":
+		return network + r.IpVersion
+	}
+	return network
+}
+
+// dialContext dials the requested address or, if the requester is bound to a specific IP address, that IP address on
+// the requested port. The URL is left untouched, so SNI and Host header are preserved. When the host gets resolved
+// here to honour the DNS timeout, its addresses are tried in order
+func (r *Requester) dialContext(ctx context.Context, network string, addr string) (net.Conn, error) {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+	host = r.pinnedHost(host)
+	addresses := []string{host}
+	if r.DnsTimeout.Duration > 0 && net.ParseIP(host) == nil {
+		if addresses, err = r.lookup(ctx, host); err != nil {
+			return nil, err
+		}
+	}
+	dialer := net.Dialer{Timeout: r.ConnectTimeout.Duration}
+	var conn net.Conn
+	for _, address := range addresses {
+		conn, err = dialer.DialContext(ctx, r.network(network), net.JoinHostPort(address, port))
+		if err == nil || ctx.Err() != nil {
+			break
+		}
+	}
+	return conn, err
+}
+
+// pinnedHost returns the IP address the requester is bound to when the host is the one of the URL, or the host itself.
+// Hosts reached through redirects are dialed as they are
+func (r *Requester) pinnedHost(host string) string {
+	if r.address != "" {
+		if parsedUrl, err := url.Parse(r.Url); err == nil && parsedUrl.Hostname() == host {
+			return r.address
+		}
+	}
+	return host
+}
+
+// ipFamily returns the family of the given IP address, either "IPv4" or "IPv6"
+func ipFamily(address string) string {
+	ip := net.ParseIP(address)
+	if ip == nil {
+		return ""
+	}
+	if ip.To4() != nil {
+		return "IPv4"
+	}
+	return "IPv6"
+}
+
+// newTransport builds the transport for the call, according to the requester settings
+func (r *Requester) newTransport() (http.RoundTripper, error) {
+	if r.Protocol == "http3" {
+		if err := r.validateHttp3(); err != nil {
+			return nil, err
+		}
+		return newHttp3Transport(r), nil
+	}
+	protocols, err := r.protocols()
+	if err != nil {
+		return nil, err
+	}
+	transport, err := r.httpTransport()
+	if err != nil {
+		return nil, err
+	}
+	transport.Protocols = protocols
+	return transport, nil
+}
+
+// httpTransport builds an HTTP/1.1 and HTTP/2 transport honouring the proxy, the IP version, the phase timeouts and
+// the SSL settings of the requester. It's also used for the side calls, such as the OAuth2 token requests
+func (r *Requester) httpTransport() (*http.Transport, error) {
+	proxy, err := r.Proxy.proxyFunc()
+	if err != nil {
+		return nil, err
+	}
+	transport := &http.Transport{
+		MaxIdleConnsPerHost:   0,
+		DialContext:           r.dialContext,
+		Proxy:                 proxy,
+		TLSHandshakeTimeout:   r.TlsTimeout.Duration,
+		ResponseHeaderTimeout: r.TtfbTimeout.Duration,
+	}
+	if r.SkipSSL {
+		transport.TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
+	}
+	return transport, nil
+}
+
+// protocols returns the HTTP protocols the transport is allowed to use. "http1.1" and "http2" force the protocol,
+// "h2c" forces HTTP/2 over cleartext, "http3" is handled by a dedicated transport and "auto" lets the client
+// negotiate between HTTP/1.1 and HTTP/2
+func (r *Requester) protocols() (*http.Protocols, error) {
+	protocols := new(http.Protocols)
+	switch r.Protocol {
+	case "", "auto":
+		protocols.SetHTTP1(true)
+		protocols.SetHTTP2(true)
+	case "http1.1":
+		protocols.SetHTTP1(true)
+	case "http2":
+		protocols.SetHTTP2(true)
+	case "h2c":
+		protocols.SetUnencryptedHTTP2(true)
+	default:
+		return nil, errors.New("invalid protocol, either 'http1.1', 'http2', 'h2c', 'http3' or 'auto'")
+	}
+	return protocols, nil
+}
+
+// closeTransport releases the connections held by the transport
+func closeTransport(transport http.RoundTripper) {
+	if closer, ok := transport.(io.Closer); ok {
+		_ = closer.Close()
+	} else if httpTransport, ok := transport.(*http.Transport); ok {
+		httpTransport.CloseIdleConnections()
+	}
+}
+
+// runSequence performs the call as many times as requested by Repeat, sharing the transport so that the connection
+// can be kept alive and reused
+func (r *Requester) runSequence() []Outcome {
+	transport, err := r.newTransport()
+	if err != nil {
+		return []Outcome{{Requester: *r, StartTime: time.Now(), Err: &RedError{Err: err, Kind: ErrorKindConfig}}}
+	}
+	defer closeTransport(transport)
+	outcomes := make([]Outcome, 0)
+	for i := 0; i < r.Repeat || i == 0; i++ {
+		outcomes = append(outcomes, r.execute(transport))
+	}
+	return outcomes
 }
 
 // run performs the call
 func (r *Requester) run() Outcome {
-	outcome := Outcome{Requester: *r}
-	request, _ := http.NewRequest(r.Method, r.Url, bytes.NewReader([]byte(r.Body)))
+	transport, err := r.newTransport()
+	if err != nil {
+		return Outcome{Requester: *r, StartTime: time.Now(), Err: &RedError{Err: err, Kind: ErrorKindConfig}}
+	}
+	defer closeTransport(transport)
+	return r.execute(transport)
+}
+
+// execute performs the call using the provided transport. With OAuth2, the token is acquired first. With digest
+// authentication, the challenge round-trip is performed first. Both are timed separately from the call metrics
+func (r *Requester) execute(transport http.RoundTripper) Outcome {
+	if err := r.Auth.validate(); err != nil {
+		return Outcome{Requester: *r, StartTime: time.Now(), Err: &RedError{Err: err, Kind: ErrorKindConfig}}
+	}
+	body, err := r.buildBody()
+	if err != nil {
+		return Outcome{Requester: *r, StartTime: time.Now(), Err: &RedError{Err: err, Kind: ErrorKindConfig}}
+	}
+	authorization := ""
+	var token *TokenAcquisition
+	if r.OAuth2 != nil {
+		var acquisition TokenAcquisition
+		tokenTransport, err := r.httpTransport()
+		if err == nil {
+			authorization, acquisition, err = r.OAuth2.authorization(r.Timeout.Duration, tokenTransport)
+		}
+		token = &acquisition
+		if err != nil {
+			return Outcome{Requester: *r, StartTime: time.Now(), Token: token,
+				Err: &RedError{Err: err, Kind: ErrorKindAuth, Phase: "auth"}}
+		}
+	}
+	outcome := r.roundTrip(transport, authorization, body)
+	if r.Auth.isDigest() && outcome.Err == nil && outcome.StatusCode == http.StatusUnauthorized {
+		authorization, err := r.Auth.digestAuthorization(r.Method, r.Url, outcome.Header)
+		if err != nil {
+			outcome.Err = &RedError{Err: err, Kind: ErrorKindAuth, Phase: "auth"}
+		} else {
+			challenge := outcome.Metrics
+			outcome = r.roundTrip(transport, authorization, body)
+			outcome.Challenge = &challenge
+		}
+	}
+	outcome.Token = token
+	if !r.compiled {
+		r.compile(nil)
+	}
+	executeAnnotations(r.compiledAnnotations, &outcome)
+	executeAssertions(r.compiledAssertions, &outcome)
+	if !r.keepResponse {
+		outcome.Header = nil
+		outcome.bodyBytes = nil
+		outcome.cookies = nil
+		outcome.sentBody = requestBody{}
+	}
+	return outcome
+}
+
+// roundTrip performs a single round-trip using the provided transport. The authorization, if any, overrides the
+// Authorization header. The request is signed last, so that the signature covers all headers
+func (r *Requester) roundTrip(transport http.RoundTripper, authorization string, body requestBody) Outcome {
+	outcome := Outcome{Requester: *r, IpAddress: r.address, IpFamily: ipFamily(r.address)}
+	ctx, cancel := context.WithCancelCause(context.Background())
+	defer cancel(nil)
+	request, _ := http.NewRequestWithContext(ctx, r.Method, r.Url, bytes.NewReader(body.data))
 	for k, v := range r.Headers {
 		request.Header.Set(k, v)
 	}
+	if body.contentType != "" && (request.Header.Get("Content-Type") == "" || r.Multipart != nil) {
+		request.Header.Set("Content-Type", body.contentType)
+	}
+	outcome.sentBody = requestBody{data: body.data, contentType: request.Header.Get("Content-Type")}
+	r.Auth.apply(request)
+	if authorization != "" {
+		request.Header.Set("Authorization", authorization)
+	}
+	if err := r.Signing.sign(request, body.data, time.Now()); err != nil {
+		outcome.StartTime = time.Now()
+		outcome.Err = &RedError{Err: err, Kind: ErrorKindConfig}
+		return outcome
+	}
 	rt := newRedTracer()
 	request = rt.addContext(request)
-	transport := &http.Transport{
-		MaxIdleConnsPerHost: 0,
+	if httpTransport, ok := transport.(*http.Transport); ok && httpTransport.Proxy != nil {
+		proxyUrl, _ := httpTransport.Proxy(request)
+		rt.proxied = proxyUrl != nil
 	}
-	if r.SkipSSL {
-		transport.TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
+	client := http.Client{Timeout: r.Timeout.Duration, Transport: transport, CheckRedirect: checkRedirect}
+	if r.Cookies {
+		client.Jar = cookieJar
 	}
-	client := http.Client{Timeout: r.Timeout.Duration, Transport: transport}
 	outcome.StartTime = time.Now()
+	rt.start = outcome.StartTime
 	res, err := client.Do(request)
 	if err != nil {
-		outcome.Err = &RedError{err}
+		phase, _ := rt.phase()
+		outcome.Err = newRedError(r.phaseTimeoutError(rt, err), phase)
 		applyMetricsToOutcome(rt, &outcome)
 		return outcome
 	}
+	if r.BodyTimeout.Duration > 0 {
+		timer := time.AfterFunc(r.BodyTimeout.Duration, func() {
+			cancel(context.DeadlineExceeded)
+		})
+		defer timer.Stop()
+	}
 	bodyBytes, err := io.ReadAll(res.Body)
 	outcome.Size = len(bodyBytes)
 	if err != nil {
-		outcome.Err = &RedError{err}
+		outcome.Err = newRedError(r.phaseTimeoutError(rt, err), "body")
 	}
 	if res.Body != nil {
 		_ = res.Body.Close()
 	}
 	rt.stop()
 	outcome.StatusCode = res.StatusCode
-	outcome.IpAddress = rt.ipAddress
+	ipAddress := rt.ipAddress
+	if http3Transport, ok := transport.(*Http3Transport); ok && ipAddress == "" {
+		ipAddress = http3Transport.remoteAddress(request.URL)
+	}
+	if ipAddress != "" {
+		outcome.IpAddress = ipAddress
+		outcome.IpFamily = ipFamily(ipAddress)
+	}
+	outcome.Connection = rt.connection
 	outcome.bodyBytes = bodyBytes
 	outcome.Header = res.Header
-	outcome.httpVersion = fmt.Sprintf("HTTP/%d.%d", res.ProtoMajor, res.ProtoMinor)
+	outcome.Http3Advertised = http3Advertised(res.Header)
+	outcome.Protocol = fmt.Sprintf("HTTP/%d.%d", res.ProtoMajor, res.ProtoMinor)
 	outcome.statusText = res.Status
 	outcome.cookies = res.Cookies()
 	applyMetricsToOutcome(rt, &outcome)
-	executeAnnotations(r.Annotations, &outcome)
-	executeAssertions(r.Assertions, &outcome)
-	if !r.keepResponse {
-		outcome.Header = nil
-		outcome.bodyBytes = nil
-		outcome.cookies = nil
-	}
 	return outcome
 }
 
-// getContentType retrieves the content type from the request headers
-func (r *Requester) getContentType() string {
-	if res, ok := r.Headers["Content-Type"]; ok {
-		return res
-	}
-	return r.Headers["content-type"]
+// outcomeEnv is the outcome as seen by the expressions, exposing the error as Error on top of Err
+type outcomeEnv struct {
+	*Outcome
+	Error *RedError
+}
+
+// expressionEnv returns the environment the annotations and assertions are evaluated against
+func expressionEnv(outcome *Outcome) map[string]interface{} {
+	env := outcomeEnv{Outcome: outcome, Error: outcome.Err}
+	return map[string]interface{}{"Response": env, "Outcome": env, "Row": outcome.Requester.Row,
+		"Matrix": outcome.Requester.Matrix}
 }
 
 // executeAnnotations will execute the annotations and store the results in outcome
-func executeAnnotations(annotations []string, outcome *Outcome) {
+func executeAnnotations(annotations []expression, outcome *Outcome) {
+	env := expressionEnv(outcome)
 	for _, annotation := range annotations {
-		env := map[string]interface{}{"Response": outcome, "Outcome": outcome}
-		program, err := expr.Compile(annotation, expr.Env(env))
-		if err != nil {
-			outcome.Annotations = append(outcome.Annotations, Annotation{annotation, err.Error()})
+		if annotation.err != nil {
+			outcome.Annotations = append(outcome.Annotations, Annotation{annotation.source, annotation.err.Error()})
 			continue
 		}
-		result, err := expr.Run(program, env)
-		outcome.Annotations = append(outcome.Annotations, Annotation{annotation, result})
+		result, _ := expr.Run(annotation.program, env)
+		outcome.Annotations = append(outcome.Annotations, Annotation{annotation.source, result})
 	}
 }
 
-// executeAssertions will execute all assertions and store the results in outcome
-func executeAssertions(assertions []string, outcome *Outcome) {
+// executeAssertions will execute all assertions and store the results in outcome. The labels of the failing
+// assertions with the warn severity are stored as the warnings of the outcome
+func executeAssertions(assertions []expression, outcome *Outcome) {
+	env := expressionEnv(outcome)
 	for _, assertion := range assertions {
-		env := map[string]interface{}{"Response": outcome, "Outcome": outcome}
-		program, err := expr.Compile(assertion, expr.Env(env))
-		if err != nil {
-			outcome.Checks = append(outcome.Checks, Check{false, err.Error(), assertion})
-			continue
-		}
-		result, err := expr.Run(program, env)
-		if err != nil {
-			outcome.Checks = append(outcome.Checks, Check{false, err.Error(), assertion})
-			continue
+		var check Check
+		if assertion.err != nil {
+			check = assertion.checkError(assertion.err)
+		} else if result, err := expr.Run(assertion.program, env); err != nil {
+			check = assertion.checkError(err)
+		} else {
+			switch v := result.(type) {
+			case int:
+				check = assertion.check(v == 1, v)
+			case bool:
+				check = assertion.check(v, v)
+			case string:
+				check = assertion.check(strings.ToLower(strings.TrimSpace(v)) == "ok", v)
+			default:
+				check = assertion.checkError(fmt.Errorf("unsupported assertion result %v of type %T", v, v))
+			}
 		}
-		switch v := result.(type) {
-		case int:
-			outcome.Checks = append(outcome.Checks, Check{v == 1, v, assertion})
-		case bool:
-			result = strconv.FormatBool(v)
-			outcome.Checks = append(outcome.Checks, Check{v, v, assertion})
-		case string:
-			result = v
-			outcome.Checks = append(outcome.Checks, Check{strings.ToLower(strings.TrimSpace(v)) == "ok", v, assertion})
+		outcome.Checks = append(outcome.Checks, check)
+		if !check.Success && check.Severity == SeverityWarn {
+			outcome.Warnings = append(outcome.Warnings, check.label())
 		}
 	}
 }
 
 // applyMetricsToOutcome takes the data from the tracer and applies them to the outcome
 func applyMetricsToOutcome(rt *RedTracer, outcome *Outcome) {
-	outcome.Metrics = Metrics{DNS: rt.dns(), TLS: rt.tls(), Conn: rt.conn(), TTFB: rt.ttfb(), Transfer: rt.transfer(), RT: rt.rt()}
+	outcome.Events = rt.timeline()
+	outcome.Metrics = Metrics{Blocked: rt.blocked(), DNS: rt.dns(), TLS: rt.tls(), Conn: rt.conn(), Proxy: rt.proxy(),
+		Send: rt.send(), TTFB: rt.ttfb(), Transfer: rt.transfer(), RT: rt.rt()}
 }
@@ -0,0 +1,197 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"hash"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Signing is the configuration of the request signature. The "aws" type computes an AWS Signature Version 4, taking
+// the credentials from the environment. The "hmac" type computes an HMAC of the method, the path, the selected headers
+// and the body hash, one per line, and writes it into a header
+type Signing struct {
+	Type      string   `json:"type" yaml:"type"`
+	Region    string   `json:"region,omitempty" yaml:"region"`
+	Service   string   `json:"service,omitempty" yaml:"service"`
+	Key       string   `json:"-" yaml:"key"`
+	Algorithm string   `json:"algorithm,omitempty" yaml:"algorithm"`
+	Headers   []string `json:"headers,omitempty" yaml:"headers"`
+	Header    string   `json:"header,omitempty" yaml:"header"`
+	Encoding  string   `json:"encoding,omitempty" yaml:"encoding"`
+}
+
+// sign signs the request, adding the signature headers. It needs to be invoked after all other headers have been set
+func (s *Signing) sign(request *http.Request, body []byte, now time.Time) error {
+	if s == nil {
+		return nil
+	}
+	switch s.Type {
+	case "aws":
+		return s.signAws(request, body, now)
+	case "hmac":
+		return s.signHmac(request, body)
+	}
+	return errors.New("invalid signing type, either 'aws' or 'hmac'")
+}
+
+// signAws signs the request with AWS Signature Version 4
+func (s *Signing) signAws(request *http.Request, body []byte, now time.Time) error {
+	accessKey := os.Getenv("AWS_ACCESS_KEY_ID")
+	secretKey := os.Getenv("AWS_SECRET_ACCESS_KEY")
+	if accessKey == "" || secretKey == "" {
+		return errors.New("AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY must be set for AWS signing")
+	}
+	region := s.Region
+	if region == "" {
+		region = os.Getenv("AWS_REGION")
+	}
+	if region == "" {
+		region = os.Getenv("AWS_DEFAULT_REGION")
+	}
+	if region == "" || s.Service == "" {
+		return errors.New("AWS signing requires a region and a service")
+	}
+	amzDate := now.UTC().Format("20060102T150405Z")
+	date := amzDate[:8]
+	payloadHash := hashHex(body)
+	request.Header.Set("X-Amz-Date", amzDate)
+	if token := os.Getenv("AWS_SESSION_TOKEN"); token != "" {
+		request.Header.Set("X-Amz-Security-Token", token)
+	}
+	if s.Service == "s3" {
+		request.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	}
+	headers := map[string]string{"host": request.Host}
+	if request.Host == "" {
+		headers["host"] = request.URL.Host
+	}
+	for name, values := range request.Header {
+		name = strings.ToLower(name)
+		if strings.HasPrefix(name, "x-amz-") || name == "content-type" {
+			headers[name] = strings.Join(values, ",")
+		}
+	}
+	names := make([]string, 0)
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	canonicalHeaders := ""
+	for _, name := range names {
+		canonicalHeaders += name + ":" + strings.Join(strings.Fields(headers[name]), " ") + "\n"
+	}
+	signedHeaders := strings.Join(names, ";")
+	canonicalRequest := strings.Join([]string{request.Method, canonicalUri(request.URL, s.Service),
+		canonicalQuery(request.URL), canonicalHeaders, signedHeaders, payloadHash}, "\n")
+	scope := strings.Join([]string{date, region, s.Service, "aws4_request"}, "/")
+	stringToSign := strings.Join([]string{"AWS4-HMAC-SHA256", amzDate, scope, hashHex([]byte(canonicalRequest))}, "\n")
+	key := []byte("AWS4" + secretKey)
+	for _, value := range []string{date, region, s.Service, "aws4_request"} {
+		key = hmacSum(sha256.New, key, []byte(value))
+	}
+	signature := hex.EncodeToString(hmacSum(sha256.New, key, []byte(stringToSign)))
+	request.Header.Set("Authorization", "AWS4-HMAC-SHA256 Credential="+accessKey+"/"+scope+", SignedHeaders="+
+		signedHeaders+", Signature="+signature)
+	return nil
+}
+
+// signHmac signs the request with the configured HMAC scheme
+func (s *Signing) signHmac(request *http.Request, body []byte) error {
+	if s.Key == "" {
+		return errors.New("HMAC signing requires a key")
+	}
+	var newHash func() hash.Hash
+	switch strings.ToLower(s.Algorithm) {
+	case "", "sha256":
+		newHash = sha256.New
+	case "sha1":
+		newHash = sha1.New
+	case "sha512":
+		newHash = sha512.New
+	default:
+		return errors.New("invalid HMAC algorithm, either 'sha256', 'sha1' or 'sha512'")
+	}
+	lines := []string{request.Method, request.URL.RequestURI()}
+	for _, name := range s.Headers {
+		value := request.Header.Get(name)
+		if strings.EqualFold(name, "host") {
+			value = request.URL.Host
+		}
+		lines = append(lines, strings.ToLower(name)+":"+strings.TrimSpace(value))
+	}
+	lines = append(lines, hashHex(body))
+	signature := hmacSum(newHash, []byte(interpolate(s.Key)), []byte(strings.Join(lines, "\n")))
+	header := s.Header
+	if header == "" {
+		header = "X-Signature"
+	}
+	switch s.Encoding {
+	case "", "base64":
+		request.Header.Set(header, base64.StdEncoding.EncodeToString(signature))
+	case "hex":
+		request.Header.Set(header, hex.EncodeToString(signature))
+	default:
+		return errors.New("invalid HMAC encoding, either 'base64' or 'hex'")
+	}
+	return nil
+}
+
+// canonicalUri returns the path with each segment URI-encoded twice, as required by AWS signing, except for S3 that
+// expects the segments encoded once
+func canonicalUri(requestUrl *url.URL, service string) string {
+	segments := strings.Split(requestUrl.EscapedPath(), "/")
+	for i, segment := range segments {
+		if unescaped, err := url.PathUnescape(segment); err == nil {
+			segment = unescaped
+		}
+		segments[i] = awsEscape(segment)
+		if service != "s3" {
+			segments[i] = awsEscape(segments[i])
+		}
+	}
+	if path := strings.Join(segments, "/"); path != "" {
+		return path
+	}
+	return "/"
+}
+
+// canonicalQuery returns the query string with keys and values encoded and sorted, as required by AWS signing
+func canonicalQuery(requestUrl *url.URL) string {
+	pairs := make([]string, 0)
+	for key, values := range requestUrl.Query() {
+		for _, value := range values {
+			pairs = append(pairs, awsEscape(key)+"="+awsEscape(value))
+		}
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, "&")
+}
+
+// awsEscape URI-encodes the value as required by AWS signing
+func awsEscape(value string) string {
+	return strings.ReplaceAll(url.QueryEscape(value), "+", "%20")
+}
+
+// hashHex returns the hex-encoded SHA-256 hash of the data
+func hashHex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// hmacSum returns the HMAC of the data with the given key
+func hmacSum(newHash func() hash.Hash, key []byte, data []byte) []byte {
+	mac := hmac.New(newHash, key)
+	mac.Write(data)
+	return mac.Sum(nil)
+}
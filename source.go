@@ -0,0 +1,239 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+
+	"gopkg.in/yaml.v2"
+	yamlv3 "gopkg.in/yaml.v3"
+)
+
+// typeErrorPattern matches the messages of the YAML type errors, as in "line 3: field timout not found in type ..."
+var typeErrorPattern = regexp.MustCompile(`^line (\d+): (.*)$`)
+
+// unknownFieldPattern matches the messages of the YAML strict decoding errors about unknown keys
+var unknownFieldPattern = regexp.MustCompile(`^field (\S+) not found in type \S+$`)
+
+// sourceNode is the location of a configuration value in the configuration files, along with the locations of the
+// values of a map, by key, and of the items of a list. Values merged from defaults and environments keep their own
+// locations. The values of a map are located at their key, but the line the value itself starts at is kept as well
+type sourceNode struct {
+	path      string
+	document  int
+	line      int
+	valueLine int
+	keys      map[string]*sourceNode
+	items     []*sourceNode
+}
+
+// newSourceNode builds the source tree of the YAML node. The values of a map are located at their keys
+func newSourceNode(node *yamlv3.Node, path string, document int) *sourceNode {
+	for node.Kind == yamlv3.AliasNode && node.Alias != nil {
+		node = node.Alias
+	}
+	if node.Kind == yamlv3.DocumentNode && len(node.Content) > 0 {
+		return newSourceNode(node.Content[0], path, document)
+	}
+	source := &sourceNode{path: path, document: document, line: node.Line, valueLine: node.Line}
+	switch node.Kind {
+	case yamlv3.MappingNode:
+		source.keys = map[string]*sourceNode{}
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			value := newSourceNode(node.Content[i+1], path, document)
+			if node.Content[i].Value == "<<" {
+				for name, merged := range value.keys {
+					if _, ok := source.keys[name]; !ok {
+						source.keys[name] = merged
+					}
+				}
+				continue
+			}
+			value.line = node.Content[i].Line
+			source.keys[node.Content[i].Value] = value
+		}
+	case yamlv3.SequenceNode:
+		for _, item := range node.Content {
+			source.items = append(source.items, newSourceNode(item, path, document))
+		}
+	}
+	return source
+}
+
+// decodeSource decodes the YAML node into a generic value, as the YAML v2 decoder does, along with its source tree
+func decodeSource(node *yamlv3.Node, path string, document int) (interface{}, *sourceNode, error) {
+	data, err := yamlv3.Marshal(node)
+	if err != nil {
+		return nil, nil, err
+	}
+	var value interface{}
+	if err := yaml.Unmarshal(data, &value); err != nil {
+		return nil, nil, err
+	}
+	return value, newSourceNode(node, path, document), nil
+}
+
+// key returns the source of the value of the map with the given key, or nil
+func (s *sourceNode) key(name string) *sourceNode {
+	if s == nil {
+		return nil
+	}
+	return s.keys[name]
+}
+
+// item returns the source of the item of the list with the given index, or nil
+func (s *sourceNode) item(index int) *sourceNode {
+	if s == nil || index < 0 || index >= len(s.items) {
+		return nil
+	}
+	return s.items[index]
+}
+
+// at returns the source of the value at the path, made of map keys and list indexes. When the path can't be followed
+// all the way, the source of the deepest value found is returned
+func (s *sourceNode) at(path ...interface{}) *sourceNode {
+	source := s
+	for _, step := range path {
+		var next *sourceNode
+		switch v := step.(type) {
+		case string:
+			next = source.key(v)
+		case int:
+			next = source.item(v)
+		}
+		if next == nil {
+			return source
+		}
+		source = next
+	}
+	return source
+}
+
+// withKeys returns a copy of the source, with its own map of keys, to merge other values into
+func (s *sourceNode) withKeys() *sourceNode {
+	merged := &sourceNode{keys: map[string]*sourceNode{}}
+	if s != nil {
+		merged.path, merged.document, merged.line = s.path, s.document, s.line
+		for name, value := range s.keys {
+			merged.keys[name] = value
+		}
+	}
+	return merged
+}
+
+// concat returns the source of the concatenation of the lists, located at the second one
+func (s *sourceNode) concat(other *sourceNode) *sourceNode {
+	concatenated := &sourceNode{}
+	if other != nil {
+		concatenated.path, concatenated.document, concatenated.line = other.path, other.document, other.line
+	}
+	if s != nil {
+		concatenated.items = append(concatenated.items, s.items...)
+	}
+	if other != nil {
+		concatenated.items = append(concatenated.items, other.items...)
+	}
+	return concatenated
+}
+
+// error wraps the error into a ConfigError pointing at the value. Values with no known location leave the error as is
+func (s *sourceNode) error(err error) error {
+	if s == nil || s.path == "" {
+		return err
+	}
+	return &ConfigError{Path: s.path, Document: s.document, Line: s.line, Err: err}
+}
+
+// locate returns the path, made of map keys and list indexes, of the value found at the line, if any
+func (s *sourceNode) locate(line int) ([]interface{}, bool) {
+	for name, value := range s.keys {
+		if value.line == line || value.valueLine == line {
+			return []interface{}{name}, true
+		}
+		if path, ok := value.locate(line); ok {
+			return append([]interface{}{name}, path...), true
+		}
+	}
+	for i, item := range s.items {
+		if item.line == line && len(item.keys) == 0 {
+			return []interface{}{i}, true
+		}
+		if path, ok := item.locate(line); ok {
+			return append([]interface{}{i}, path...), true
+		}
+	}
+	return nil, false
+}
+
+// pathName returns the path in the form "auth.username" or "assertions[1]"
+func pathName(path []interface{}) string {
+	name := ""
+	for _, step := range path {
+		if index, ok := step.(int); ok {
+			name += fmt.Sprintf("[%d]", index)
+		} else if name == "" {
+			name = fmt.Sprint(step)
+		} else {
+			name += "." + fmt.Sprint(step)
+		}
+	}
+	return name
+}
+
+// locateErrors turns the type errors of decoding the YAML data into ConfigErrors pointing at the source of the values
+// the data was generated from. As the data is the YAML the values got marshalled into, the values found at the lines
+// of the errors are looked up by their path in the source tree. Unknown keys are reported by their path, and other
+// errors point at the document
+func (s *sourceNode) locateErrors(err error, data []byte) error {
+	var typeErr *yaml.TypeError
+	if !errors.As(err, &typeErr) {
+		return s.withoutLine().error(err)
+	}
+	var node yamlv3.Node
+	if err := yamlv3.Unmarshal(data, &node); err != nil {
+		return s.withoutLine().error(typeErr)
+	}
+	generated := newSourceNode(&node, "", 0)
+	errs := make([]error, 0)
+	for _, message := range typeErr.Errors {
+		match := typeErrorPattern.FindStringSubmatch(message)
+		if match == nil {
+			errs = append(errs, s.withoutLine().error(errors.New(message)))
+			continue
+		}
+		line, _ := strconv.Atoi(match[1])
+		path, ok := generated.locate(line)
+		switch {
+		case !ok:
+			errs = append(errs, s.withoutLine().error(errors.New(match[2])))
+		case unknownFieldPattern.MatchString(match[2]):
+			errs = append(errs, s.at(path...).error(fmt.Errorf("unknown key %s", pathName(path))))
+		default:
+			errs = append(errs, s.at(path...).error(errors.New(match[2])))
+		}
+	}
+	return joinErrors(errs)
+}
+
+// lineTypeError turns the error of a custom unmarshaller into a YAML type error at the line of the value, so that it
+// gets located and reported along with the other type errors. The line is the one YAML reports when the value fails to
+// unmarshal into a list
+func lineTypeError(unmarshal func(interface{}) error, err error) error {
+	var list []interface{}
+	var typeErr *yaml.TypeError
+	if errors.As(unmarshal(&list), &typeErr) && len(typeErr.Errors) > 0 {
+		if match := typeErrorPattern.FindStringSubmatch(typeErr.Errors[0]); match != nil {
+			return &yaml.TypeError{Errors: []string{fmt.Sprintf("line %s: %s", match[1], err.Error())}}
+		}
+	}
+	return err
+}
+
+// withoutLine returns a copy of the source pointing at its document only
+func (s *sourceNode) withoutLine() *sourceNode {
+	if s == nil {
+		return nil
+	}
+	return &sourceNode{path: s.path, document: s.document}
+}
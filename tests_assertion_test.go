@@ -0,0 +1,86 @@
+package main
+
+import (
+	"encoding/json"
+	"gopkg.in/yaml.v2"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestAssertionSeverities(t *testing.T) {
+	config := `
+url: https://www.example.com
+assertions:
+  - Response.StatusCode == 200
+  - expr: Response.Size >= 10
+    name: Has a body
+    message: The body is too small
+    severity: warn
+  - expr: Response.Header.Get("X-Cache") == "HIT"
+    severity: info
+`
+	var requester Requester
+	if err := yaml.Unmarshal([]byte(config), &requester); err != nil {
+		t.Fatal(err)
+	}
+	if len(requester.Assertions) != 3 || requester.Assertions[0].Expr != "Response.StatusCode == 200" ||
+		requester.Assertions[1].Name != "Has a body" || requester.Assertions[2].Severity != SeverityInfo {
+		t.Fatal("assertions not decoded", requester.Assertions)
+	}
+	outcome := Outcome{StatusCode: 200, Size: 5}
+	executeAssertions(compileAssertions(requester.Assertions, nil), &outcome)
+	if !outcome.isSuccess() || exitCode([]Outcome{outcome}) != 0 {
+		t.Error("warnings and infos failing the run")
+	}
+	if outcome.Checks[1].Output != "The body is too small" || outcome.Checks[1].Severity != SeverityWarn ||
+		outcome.Checks[1].label() != "Has a body" {
+		t.Error("wrong check", outcome.Checks[1])
+	}
+	if len(outcome.Warnings) != 1 || outcome.Warnings[0] != "Has a body" {
+		t.Error("warnings not reported", outcome.Warnings)
+	}
+	outcome = Outcome{StatusCode: 500, Size: 50}
+	executeAssertions(compileAssertions(requester.Assertions, nil), &outcome)
+	if outcome.isSuccess() || exitCode([]Outcome{outcome}) != 1 || outcome.Checks[0].Severity != SeverityError {
+		t.Error("errors not failing the run")
+	}
+	outcome = Outcome{StatusCode: 200, Size: 5}
+	executeAssertions(compileAssertions([]Assertion{{Expr: "Response.Size.Foo > 10", Message: "Too small"},
+		{Expr: "Response.Size * 1.5", Message: "Not a check"}}, nil), &outcome)
+	if len(outcome.Checks) != 2 || outcome.Checks[0].Success || outcome.Checks[0].Output == "Too small" {
+		t.Error("assertion error hidden by the message", outcome.Checks)
+	}
+	if outcome.Checks[1].Success || outcome.Checks[1].Output == "Not a check" || outcome.isSuccess() {
+		t.Error("unsupported assertion result not failing", outcome.Checks)
+	}
+	data, _ := json.Marshal(requester.Assertions[:2])
+	if !strings.HasPrefix(string(data), `["Response.StatusCode == 200",{"expr":"Response.Size \u003e= 10"`) {
+		t.Error("wrong JSON", string(data))
+	}
+}
+
+func TestAssertionErrors(t *testing.T) {
+	dir := writeConfigFiles(t, map[string]string{
+		"severity.yaml": "url: https://www.example.com\nassertions:\n  - expr: Response.StatusCode == 200\n" +
+			"    severity: fatal\n",
+		"key.yaml": "url: https://www.example.com\nassertions:\n  - expression: Response.StatusCode == 200\n",
+		"default.yaml": "url: https://www.example.com\nassertions:\n  - expr: Response.StatusCode == 200\n" +
+			"    name: Up\n  - expr: Response.Size > 0\n    message: Empty body\n",
+	})
+	requesters, err := loadConfig(filepath.Join(dir, "default.yaml"), "", "")
+	if err != nil || len(requesters[0].Assertions) != 2 || requesters[0].Assertions[1].severity() != SeverityError {
+		t.Error("assertion objects without severity rejected", err)
+	}
+	if _, errs := validateConfig(filepath.Join(dir, "default.yaml"), "", ""); len(errs) > 0 {
+		t.Error("assertion objects without severity not valid", errs)
+	}
+	if _, err := loadConfig(filepath.Join(dir, "severity.yaml"), "", ""); err == nil ||
+		!strings.Contains(err.Error(), "unknown assertion severity fatal") {
+		t.Error("unknown severity not reported", err)
+	}
+	if _, err := loadConfig(filepath.Join(dir, "key.yaml"), "", ""); err == nil ||
+		!strings.Contains(err.Error(), "unknown assertion key expression") {
+		t.Error("unknown key not reported", err)
+	}
+}
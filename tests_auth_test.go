@@ -0,0 +1,74 @@
+package main
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAuth(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		username, password, _ := r.BasicAuth()
+		if (username == "foo" && password == "s3cr3t") || r.Header.Get("Authorization") == "Bearer s3cr3t" ||
+			r.Header.Get("X-Key") == "s3cr3t" || r.URL.Query().Get("key") == "s3cr3t" {
+			return
+		}
+		w.WriteHeader(401)
+	}))
+	defer server.Close()
+	t.Setenv("REDPROBE_SECRET", "s3cr3t")
+	for _, auth := range []*Auth{
+		{Type: "basic", Username: "foo", Password: "${REDPROBE_SECRET}"},
+		{Type: "bearer", Token: "${REDPROBE_SECRET}"},
+		{Type: "apiKey", Name: "X-Key", Key: "${REDPROBE_SECRET}"},
+		{Type: "apiKey", Name: "key", Key: "${REDPROBE_SECRET}", In: "query"},
+	} {
+		r := newRequester("GET", server.URL, map[string]string{}, []byte{}, Duration{5 * time.Second}, false,
+			[]string{}, []string{})
+		r.Auth = auth
+		outcome := r.run()
+		if outcome.StatusCode != 200 {
+			t.Errorf("%s auth failed", auth.Type)
+		}
+		data, _ := json.Marshal(outcome)
+		if strings.Contains(string(data), "s3cr3t") || strings.Contains(string(data), "REDPROBE_SECRET") {
+			t.Errorf("%s auth secret not redacted", auth.Type)
+		}
+	}
+}
+
+func TestDigestAuth(t *testing.T) {
+	digest := func(values ...string) string {
+		sum := md5.Sum([]byte(strings.Join(values, ":")))
+		return hex.EncodeToString(sum[:])
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		challenge := parseDigestChallenge(r.Header.Get("Authorization"))
+		if challenge != nil {
+			ha1 := digest("foo", "redprobe", "bar")
+			ha2 := digest(r.Method, challenge["uri"])
+			expected := digest(ha1, "abc123", challenge["nc"], challenge["cnonce"], challenge["qop"], ha2)
+			if challenge["response"] == expected && challenge["opaque"] == "xyz" {
+				return
+			}
+		}
+		w.Header().Set("WWW-Authenticate", `Digest realm="redprobe", qop="auth,auth-int", nonce="abc123", opaque="xyz"`)
+		w.WriteHeader(401)
+	}))
+	defer server.Close()
+	r := newRequester("GET", server.URL+"/path?query=1", map[string]string{}, []byte{}, Duration{5 * time.Second},
+		false, []string{}, []string{})
+	r.Auth = &Auth{Type: "digest", Username: "foo", Password: "bar"}
+	outcome := r.run()
+	if outcome.StatusCode != 200 {
+		t.Error("Digest auth failed")
+	}
+	if outcome.Challenge == nil || outcome.Challenge.RT <= 0 {
+		t.Error("Challenge round-trip not timed")
+	}
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"gopkg.in/yaml.v2"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestStructuredBodies(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+		switch r.URL.Path {
+		case "/multipart":
+			if err := r.ParseMultipartForm(1 << 20); err != nil {
+				w.WriteHeader(400)
+				return
+			}
+			file, header, err := r.FormFile("upload")
+			if err != nil {
+				w.WriteHeader(400)
+				return
+			}
+			data, _ := io.ReadAll(file)
+			_, _ = w.Write([]byte(r.FormValue("name") + ":" + header.Filename + ":" + string(data)))
+		default:
+			body, _ := io.ReadAll(r.Body)
+			_, _ = w.Write([]byte(r.Header.Get("Content-Type") + ";" + string(body)))
+		}
+	}))
+	defer server.Close()
+	path := filepath.Join(t.TempDir(), "upload.txt")
+	if err := os.WriteFile(path, []byte("hello"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	config := "json:\n  id: 1\n  tags:\n    - a\nform:\n  q: a b\n"
+	requester := Requester{}
+	if err := yaml.Unmarshal([]byte(config), &requester); err != nil {
+		t.Fatal(err)
+	}
+	r := newRequester("POST", server.URL, map[string]string{}, []byte{}, Duration{5 * time.Second}, false,
+		[]string{}, []string{})
+	r.keepResponse = true
+	r.Json = requester.Json
+	if outcome := r.run(); string(outcome.bodyBytes) != `application/json;{"id":1,"tags":["a"]}` {
+		t.Error("wrong JSON body", string(outcome.bodyBytes))
+	}
+	r.Form = requester.Form
+	if outcome := r.run(); outcome.Err == nil || outcome.Err.Kind != ErrorKindConfig {
+		t.Error("multiple bodies not reported")
+	}
+	r.Json = nil
+	outcome := r.run()
+	if string(outcome.bodyBytes) != "application/x-www-form-urlencoded;q=a+b" {
+		t.Error("wrong form body", string(outcome.bodyBytes))
+	}
+	if har := toHar([]Outcome{outcome}); har.Log.Entries[0].Request.PostData.Params[0].Value != "a b" {
+		t.Error("wrong HAR form params")
+	}
+	r.Form = nil
+	r.BodyFile = path
+	if outcome := r.run(); string(outcome.bodyBytes) != "text/plain; charset=utf-8;hello" {
+		t.Error("wrong file body", string(outcome.bodyBytes))
+	}
+	r.BodyFile = ""
+	r.Url = server.URL + "/multipart"
+	r.Multipart = &Multipart{Fields: map[string]string{"name": "probe"}, Files: map[string]string{"upload": path}}
+	outcome = r.run()
+	if string(outcome.bodyBytes) != "probe:upload.txt:hello" {
+		t.Error("wrong multipart body", string(outcome.bodyBytes))
+	}
+	params := toHar([]Outcome{outcome}).Log.Entries[0].Request.PostData.Params
+	if len(params) != 2 || params[1].FileName != "upload.txt" || params[1].ContentType != "text/plain; charset=utf-8" {
+		t.Error("wrong HAR multipart params")
+	}
+}
@@ -16,7 +16,7 @@ func TestCli(t *testing.T) {
 	req := newRequester("GET", "https://www.google.com", map[string]string{"Accept": "text/html"}, []byte{},
 		Duration{10 * time.Second}, false, []string{}, []string{})
 	outcome := req.run()
-	printToCli([]Outcome{outcome}, "console")
+	printToCli([][]Outcome{{outcome}}, "console")
 	outC := make(chan string)
 	go func() {
 		var buf bytes.Buffer
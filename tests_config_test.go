@@ -0,0 +1,202 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeConfigFiles writes the configuration files into a temporary directory, returning the directory
+func writeConfigFiles(t *testing.T, files map[string]string) string {
+	dir := t.TempDir()
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestDefaultsAndIncludes(t *testing.T) {
+	dir := writeConfigFiles(t, map[string]string{
+		"calls.yaml": "defaults:\n  timeout: 10s\n  headers:\n    user-agent: redProbe/1\n  assertions:\n" +
+			"    - include: assertions.yaml\n---\nurl: https://www.example.com\nheaders:\n    accept: text/html\n" +
+			"assertions:\n  - Response.Size > 0\n---\ninclude: more.yaml\n",
+		"assertions.yaml": "- Response.StatusCode == 200\n",
+		"more.yaml":       "url: https://www.example.com/more\ntimeout: 1s\n",
+	})
+	requesters, err := loadConfig(filepath.Join(dir, "calls.yaml"), "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(requesters) != 2 {
+		t.Fatal("wrong number of requesters")
+	}
+	if requesters[0].Timeout.Seconds() != 10 || requesters[0].Headers["user-agent"] != "redProbe/1" ||
+		requesters[0].Headers["accept"] != "text/html" {
+		t.Error("defaults not merged")
+	}
+	if len(requesters[0].Assertions) != 2 || requesters[0].Assertions[0].Expr != "Response.StatusCode == 200" ||
+		requesters[0].Assertions[1].Expr != "Response.Size > 0" {
+		t.Error("assertions not merged", requesters[0].Assertions)
+	}
+	if requesters[1].Url != "https://www.example.com/more" || requesters[1].Timeout.Seconds() != 1 ||
+		len(requesters[1].Assertions) != 1 {
+		t.Error("included document not loaded")
+	}
+}
+
+func TestIncludeErrors(t *testing.T) {
+	dir := writeConfigFiles(t, map[string]string{
+		"a.yaml":      "url: https://www.example.com\n---\ninclude: b.yaml\n",
+		"b.yaml":      "include: a.yaml\n",
+		"broken.yaml": "url: https://www.example.com\n---\ntimeout: soon\n",
+		"typo.yaml":   "url: https://www.example.com\ntimout: 3s\n",
+	})
+	_, err := loadConfig(filepath.Join(dir, "a.yaml"), "", "")
+	if err == nil || !strings.Contains(err.Error(), "include cycle") {
+		t.Error("include cycle not detected", err)
+	}
+	_, err = loadConfig(filepath.Join(dir, "broken.yaml"), "", "")
+	if err == nil || !strings.Contains(err.Error(), "broken.yaml, document 2, line 3: invalid duration soon") {
+		t.Error("error location not reported", err)
+	}
+	_, err = loadConfig(filepath.Join(dir, "typo.yaml"), "", "")
+	if err == nil || !strings.Contains(err.Error(), "typo.yaml, document 1, line 2: unknown key timout") {
+		t.Error("unknown key not rejected", err)
+	}
+}
+
+func TestEnvironments(t *testing.T) {
+	dir := writeConfigFiles(t, map[string]string{
+		"calls.yaml": "environments:\n  staging:\n    baseUrl: https://staging.example.com\n    skipSSL: true\n" +
+			"    variables:\n      USER: probe\n  prod:\n    baseUrl: https://www.example.com\n---\n" +
+			"path: /users/${USER}\ntimeout: 2s\nauth:\n  type: basic\n  username: ${USER}\n  password: ${PASSWORD}\n",
+	})
+	requesters, err := loadConfig(filepath.Join(dir, "calls.yaml"), "", "staging")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if requesters[0].Url != "https://staging.example.com/users/probe" || !requesters[0].SkipSSL ||
+		requesters[0].Timeout.Seconds() != 2 || requesters[0].Environment != "staging" {
+		t.Error("environment not applied", requesters[0].Url)
+	}
+	if requesters[0].Auth.Username != "probe" || requesters[0].Auth.Password != "${PASSWORD}" {
+		t.Error("variables not interpolated")
+	}
+	if har := toHar([]Outcome{{Requester: requesters[0]}}); har.Log.Entries[0].Environment != "staging" {
+		t.Error("environment not recorded")
+	}
+	_, err = loadConfig(filepath.Join(dir, "calls.yaml"), "", "dev")
+	if err == nil || !strings.Contains(err.Error(), "unknown environment dev, either 'prod', 'staging'") {
+		t.Error("unknown environment not reported", err)
+	}
+}
+
+func TestDataset(t *testing.T) {
+	dir := writeConfigFiles(t, map[string]string{
+		"calls.yaml": "url: https://www.example.com/tenants/${tenant}\ndataset: tenants.csv\n---\n" +
+			"url: https://www.example.com/tenants/${tenant}\ndataset: tenants.jsonl\n" +
+			"assertions:\n  - Row.limit > 5\n",
+		"tenants.csv":   "tenant,region\nacme,eu\nglobex,us\n",
+		"tenants.jsonl": "{\"tenant\":\"initech\",\"limit\":10}\n\n{\"tenant\":\"umbrella\",\"limit\":1}\n",
+		"ids.yaml":      "url: https://www.example.com/tenants/${id}?ratio=${ratio}\ndataset: ids.json\n",
+		"ids.json":      "[{\"id\":1234567,\"ratio\":0.5},{\"id\":9007199254740993,\"ratio\":2}]",
+	})
+	ids, err := loadConfig(filepath.Join(dir, "ids.yaml"), "", "")
+	if err != nil || len(ids) != 2 {
+		t.Fatal("numeric dataset not loaded", err)
+	}
+	if ids[0].Url != "https://www.example.com/tenants/1234567?ratio=0.5" ||
+		ids[1].Url != "https://www.example.com/tenants/9007199254740993?ratio=2" {
+		t.Error("numeric IDs not interpolated as integers", ids[0].Url, ids[1].Url)
+	}
+	requesters, err := loadConfig(filepath.Join(dir, "calls.yaml"), "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(requesters) != 4 {
+		t.Fatal("dataset not expanded")
+	}
+	if requesters[1].Url != "https://www.example.com/tenants/globex" || requesters[1].RowIndex != 2 ||
+		requesters[1].Row["region"] != "us" {
+		t.Error("row not interpolated", requesters[1].Url)
+	}
+	if rowLabel(requesters[1].RowIndex, requesters[1].Row) != "#2 region=us, tenant=globex" {
+		t.Error("wrong row label")
+	}
+	runs := make([][]Outcome, 0)
+	for _, requester := range append([]Requester{requesters[0]}, requesters...) {
+		runs = append(runs, []Outcome{{Requester: requester}})
+	}
+	if groups := rowGroups(runs); len(groups) != 4 || len(groups[0]) != 2 || groups[2][0].Requester.RowIndex != 1 {
+		t.Error("outcomes not grouped per row", len(groups))
+	}
+	for i, expected := range []bool{true, false} {
+		outcome := Outcome{Requester: requesters[2+i], StatusCode: 200}
+		executeAssertions(compileAssertions(outcome.Requester.Assertions, nil), &outcome)
+		if outcome.Checks[0].Success != expected {
+			t.Error("row not available to assertions")
+		}
+	}
+}
+
+func TestMatrix(t *testing.T) {
+	dir := writeConfigFiles(t, map[string]string{
+		"calls.yaml": "name: status\nurl: https://${region}.example.com/${version}/status\nheaders:\n" +
+			"  accept: ${accept}\nmatrix:\n  region: [eu, us, ap]\n  version: [v1, v2]\n" +
+			"  accept: [application/json, text/html]\n  exclude:\n    - region: ap\n      version: v1\n",
+	})
+	requesters, err := loadConfig(filepath.Join(dir, "calls.yaml"), "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(requesters) != 10 {
+		t.Fatal("wrong number of combinations", len(requesters))
+	}
+	for _, requester := range requesters {
+		if requester.Matrix["region"] == "ap" && requester.Matrix["version"] == "v1" {
+			t.Error("excluded combination generated")
+		}
+	}
+	if requesters[0].Name != "status (accept=application/json, region=eu, version=v1)" ||
+		requesters[0].Url != "https://eu.example.com/v1/status" || requesters[0].Headers["accept"] != "application/json" {
+		t.Error("combination not applied", requesters[0].Name, requesters[0].Url)
+	}
+}
+
+func TestInterpolatedErrors(t *testing.T) {
+	dir := writeConfigFiles(t, map[string]string{
+		"calls.yaml": "environments:\n  staging:\n    variables:\n      HOST: staging.example.com\n---\n" +
+			"# the probe\nurl: https://${HOST}/status\nheaders:\n  accept:\n    - text/html\n    - ${HOST}\nrepeat: twice\n",
+	})
+	_, err := loadConfig(filepath.Join(dir, "calls.yaml"), "", "staging")
+	if err == nil {
+		t.Fatal("type errors not reported")
+	}
+	for _, expected := range []string{"calls.yaml, document 2, line 9: cannot unmarshal !!seq into string",
+		"calls.yaml, document 2, line 12: cannot unmarshal !!str `twice` into int"} {
+		if !strings.Contains(err.Error(), expected) {
+			t.Error("error not located in the source", expected, err)
+		}
+	}
+}
+
+func TestBodyPaths(t *testing.T) {
+	dir := writeConfigFiles(t, map[string]string{
+		"calls.yaml": "url: https://www.example.com\nbodyFile: payload.json\n---\nurl: https://www.example.com\n" +
+			"multipart:\n  files:\n    picture: picture.png\n    absolute: /tmp/picture.png\n",
+	})
+	requesters, err := loadConfig(filepath.Join(dir, "calls.yaml"), "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if requesters[0].BodyFile != filepath.Join(dir, "payload.json") {
+		t.Error("bodyFile not resolved relative to the configuration file", requesters[0].BodyFile)
+	}
+	files := requesters[1].Multipart.Files
+	if files["picture"] != filepath.Join(dir, "picture.png") || files["absolute"] != "/tmp/picture.png" {
+		t.Error("multipart files not resolved relative to the configuration file", files)
+	}
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestCookies(t *testing.T) {
+	cookieJar = newCookieJar()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/login" {
+			http.SetCookie(w, &http.Cookie{Name: "session", Value: "abc123", Path: "/", HttpOnly: true, MaxAge: 3600})
+			return
+		}
+		if cookie, err := r.Cookie("session"); err != nil || cookie.Value != "abc123" {
+			w.WriteHeader(401)
+		}
+	}))
+	defer server.Close()
+	login := newRequester("POST", server.URL+"/login", map[string]string{}, []byte{}, Duration{5 * time.Second}, false,
+		[]string{`Response.Cookie("session").HttpOnly`, `!Response.Cookie("session").Secure`}, []string{})
+	login.Cookies = true
+	outcome := login.run()
+	if !outcome.isSuccess() {
+		t.Error("session cookie not exposed to assertions")
+	}
+	profile := newRequester("GET", server.URL+"/profile", map[string]string{}, []byte{}, Duration{5 * time.Second},
+		false, []string{}, []string{})
+	if outcome := profile.run(); outcome.StatusCode != 401 {
+		t.Error("cookies sent without opting in")
+	}
+	profile.Cookies = true
+	if outcome := profile.run(); outcome.StatusCode != 200 {
+		t.Error("session cookie not sent")
+	}
+	path := filepath.Join(t.TempDir(), "cookies.json")
+	if err := cookieJar.save(path); err != nil {
+		t.Fatal(err)
+	}
+	cookieJar = newCookieJar()
+	if err := cookieJar.load(path); err != nil {
+		t.Fatal(err)
+	}
+	if outcome := profile.run(); outcome.StatusCode != 200 {
+		t.Error("session cookie not persisted")
+	}
+}
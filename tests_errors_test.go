@@ -0,0 +1,83 @@
+package main
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestErrorClassification(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/loop":
+			http.Redirect(w, r, "/loop", http.StatusFound)
+		case "/truncated":
+			w.Header().Set("Content-Length", "10")
+			_, _ = w.Write([]byte("12345"))
+		}
+	}))
+	defer server.Close()
+	tlsServer := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer tlsServer.Close()
+	listener, _ := net.Listen("tcp", "127.0.0.1:0")
+	closedUrl := "http://" + listener.Addr().String()
+	_ = listener.Close()
+
+	for url, kind := range map[string]string{
+		server.URL + "/loop":      ErrorKindTooManyRedirects,
+		server.URL + "/truncated": ErrorKindBodyTruncated,
+		tlsServer.URL:             ErrorKindTlsCertInvalid,
+		closedUrl:                 ErrorKindConnectRefused,
+	} {
+		r := newRequester("GET", url, map[string]string{}, []byte{}, Duration{5 * time.Second}, false,
+			[]string{}, []string{})
+		outcome := r.run()
+		if outcome.Err == nil || outcome.Err.Kind != kind {
+			t.Errorf("Error for %s not classified as %s", url, kind)
+		}
+		if exitCode([]Outcome{outcome}) != exitCodes[kind] {
+			t.Errorf("Wrong exit code for %s", kind)
+		}
+	}
+}
+
+func TestErrorAssertion(t *testing.T) {
+	outcome := Outcome{Err: &RedError{Err: net.UnknownNetworkError("foo"), Kind: ErrorKindDnsNxDomain, Phase: "dns"}}
+	executeAssertions(compileExpressions("assertion", []string{"Response.Err.Kind == \"dns_nxdomain\"",
+		"Response.Err != nil", "Response.Err.Error() == \"unknown network foo\""}, nil), &outcome)
+	for _, check := range outcome.Checks {
+		if !check.Success {
+			t.Error("Error not available to assertions", check.Assertion, check.Output)
+		}
+	}
+	data, _ := json.Marshal(outcome)
+	if !strings.Contains(string(data), `"error":"unknown network foo"`) ||
+		!strings.Contains(string(data), `"errorKind":"dns_nxdomain","errorPhase":"dns"`) {
+		t.Error("wrong JSON error", string(data))
+	}
+	data, _ = json.Marshal(Outcome{})
+	if !strings.Contains(string(data), `"error":null`) || strings.Contains(string(data), "errorKind") {
+		t.Error("wrong JSON with no error", string(data))
+	}
+	if exitCode([]Outcome{{Checks: []Check{{Success: false}}}}) != 1 || exitCode([]Outcome{{}}) != 0 {
+		t.Error("Wrong exit code for assertions")
+	}
+	listener, _ := net.Listen("tcp", "127.0.0.1:0")
+	closedUrl := "http://" + listener.Addr().String()
+	_ = listener.Close()
+	r := newRequester("GET", closedUrl, map[string]string{}, []byte{}, Duration{5 * time.Second}, false,
+		[]string{"Response.Error.Kind == \"connect_refused\"", "Response.Err.Phase == \"connect\"",
+			"Response.StatusCode == 200"}, []string{"Response.Error.Kind"})
+	outcome = r.run()
+	if len(outcome.Checks) != 3 || !outcome.Checks[0].Success || !outcome.Checks[1].Success ||
+		outcome.Checks[2].Success {
+		t.Error("Assertions not executed on the transport error", outcome.Checks)
+	}
+	if len(outcome.Annotations) != 1 || outcome.Annotations[0].Text != ErrorKindConnectRefused {
+		t.Error("Annotations not executed on the transport error", outcome.Annotations)
+	}
+}
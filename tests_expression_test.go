@@ -0,0 +1,48 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCompileExpressions(t *testing.T) {
+	dir := writeConfigFiles(t, map[string]string{
+		"calls.yaml": "url: https://www.example.com\nassertions:\n  - Response.StatusCode == 200\n" +
+			"annotations:\n  - Response.Sise\n---\nurl: https://www.example.com\nassertions:\n  - Response.Cod == 1\n",
+		"matrix.yaml": "url: https://www.example.com/${region}\nmatrix:\n  region: [eu, us]\n" +
+			"assertions:\n  - Response.StatusCode == 200\n",
+	})
+	_, err := loadConfig(filepath.Join(dir, "calls.yaml"), "", "")
+	if err == nil || !strings.Contains(err.Error(), "document 1, line 5: invalid annotation \"Response.Sise\"") ||
+		!strings.Contains(err.Error(), "document 2, line 9: invalid assertion \"Response.Cod == 1\"") {
+		t.Error("compile errors not reported at load time", err)
+	}
+	requesters, err := loadConfig(filepath.Join(dir, "matrix.yaml"), "", "")
+	if err != nil || len(requesters) != 2 {
+		t.Fatal("matrix not loaded", err)
+	}
+	if requesters[0].compiledAssertions[0].program != requesters[1].compiledAssertions[0].program {
+		t.Error("expressions of the document compiled once per requester")
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+	r := newRequester("GET", server.URL, map[string]string{}, []byte{}, Duration{5 * time.Second}, false,
+		[]string{"Response.StatusCode == 200"}, []string{"Response.Size"})
+	if failed := r.compile(nil); len(failed) > 0 {
+		t.Fatal("valid expressions failed to compile")
+	}
+	program := r.compiledAssertions[0].program
+	r.Repeat = 2
+	for _, outcome := range r.runAll() {
+		if !outcome.isSuccess() || len(outcome.Annotations) != 1 {
+			t.Error("compiled expressions not executed")
+		}
+	}
+	if r.compiledAssertions[0].program != program {
+		t.Error("expressions compiled again")
+	}
+}
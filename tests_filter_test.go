@@ -0,0 +1,35 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestFilterRequesters(t *testing.T) {
+	requesters := []Requester{
+		{Name: "login", Tags: []string{"smoke", "auth"}},
+		{Name: "search", Tags: []string{"smoke", "slow"}},
+		{Name: "status (region=eu)", Tags: []string{"health"}, documentName: "status"},
+		{Url: "https://www.example.com"},
+	}
+	names := func(requesters []Requester) string {
+		result := ""
+		for _, requester := range requesters {
+			result += requester.Name + ";"
+		}
+		return result
+	}
+	if selected := filterRequesters(requesters, nil, nil, nil); len(selected) != 4 {
+		t.Error("requesters filtered with no filters")
+	}
+	if selected := filterRequesters(requesters, []string{"login", "status"}, nil, nil); names(selected) !=
+		"login;status (region=eu);" {
+		t.Error("wrong requesters selected by name", names(selected))
+	}
+	if selected := filterRequesters(requesters, nil, []string{"smoke"}, []string{"slow"}); names(selected) !=
+		"login;" {
+		t.Error("wrong requesters selected by tags", names(selected))
+	}
+	if selected := filterRequesters(requesters, nil, nil, []string{"health"}); len(selected) != 3 {
+		t.Error("wrong requesters skipped by tags", names(selected))
+	}
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/quic-go/quic-go/http3"
+)
+
+func TestHttp3(t *testing.T) {
+	tlsServer := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer tlsServer.Close()
+	udpConn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := http3.Server{TLSConfig: http3.ConfigureTLSConfig(tlsServer.TLS),
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_, _ = w.Write([]byte("hello"))
+		})}
+	go func() { _ = server.Serve(udpConn) }()
+	defer server.Close()
+	port := strconv.Itoa(udpConn.LocalAddr().(*net.UDPAddr).Port)
+	r := newRequester("GET", "https://127.0.0.1:"+port, map[string]string{}, []byte{}, Duration{5 * time.Second},
+		true, []string{"Response.Protocol == \"HTTP/3.0\""}, []string{})
+	r.Protocol = "http3"
+	outcome := r.run()
+	if !outcome.isSuccess() || outcome.Size != 5 {
+		t.Fatal("HTTP/3 call failed", outcome.Err)
+	}
+	if outcome.Metrics.Conn.Nanoseconds() <= 0 || outcome.Metrics.TLS != 0 {
+		t.Error("QUIC handshake not traced as the connection phase")
+	}
+	r.Repeat = 2
+	for _, outcome := range r.runAll() {
+		if outcome.IpAddress != "127.0.0.1" || outcome.IpFamily != "IPv4" {
+			t.Error("HTTP/3 IP address not reported", outcome.IpAddress)
+		}
+	}
+	r.Repeat = 0
+	r.address = "::1"
+	if r.pinnedHost("127.0.0.1") != "::1" || r.pinnedHost("redirect.example.com") != "redirect.example.com" {
+		t.Error("bound IP address not limited to the URL host")
+	}
+	r.address = ""
+	r.Url = "http://127.0.0.1:" + port
+	if outcome = r.run(); outcome.Err == nil {
+		t.Error("HTTP/3 over a cleartext URL accepted")
+	}
+}
+
+func TestHttp3Advertised(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Alt-Svc", "h2=\":443\"; ma=60, h3=\":443\"; ma=86400")
+	}))
+	defer server.Close()
+	r := newRequester("GET", server.URL, map[string]string{}, []byte{}, Duration{5 * time.Second}, false,
+		[]string{"Response.Http3Advertised"}, []string{})
+	if outcome := r.run(); !outcome.isSuccess() {
+		t.Error("HTTP/3 advertisement not detected")
+	}
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestOAuth2(t *testing.T) {
+	issued := 0
+	tokenServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		clientId, clientSecret, _ := r.BasicAuth()
+		if r.FormValue("grant_type") != "client_credentials" || r.FormValue("scope") != "read write" ||
+			clientId != "probe" || clientSecret != "secret" {
+			w.WriteHeader(400)
+			return
+		}
+		issued++
+		_, _ = w.Write([]byte(`{"access_token":"abc123","token_type":"Bearer","expires_in":3600}`))
+	}))
+	defer tokenServer.Close()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer abc123" {
+			w.WriteHeader(401)
+		}
+	}))
+	defer server.Close()
+	r := newRequester("GET", server.URL, map[string]string{}, []byte{}, Duration{5 * time.Second}, false,
+		[]string{}, []string{})
+	r.OAuth2 = &OAuth2{TokenUrl: tokenServer.URL, ClientId: "probe", ClientSecret: "secret",
+		Scopes: []string{"read", "write"}}
+	r.Repeat = 2
+	outcomes := r.runAll()
+	for _, outcome := range outcomes {
+		if outcome.StatusCode != 200 {
+			t.Error("OAuth2 token not sent")
+		}
+	}
+	if issued != 1 || outcomes[0].Token.Cached || !outcomes[1].Token.Cached {
+		t.Error("OAuth2 token not cached")
+	}
+	r.OAuth2 = &OAuth2{TokenUrl: tokenServer.URL, ClientId: "probe", ClientSecret: "wrong",
+		Scopes: []string{"read", "write"}}
+	if outcome := r.run(); outcome.Err == nil || outcome.Err.Kind != ErrorKindAuth {
+		t.Error("OAuth2 token cached across client secrets")
+	}
+	r.OAuth2 = &OAuth2{TokenUrl: tokenServer.URL, ClientId: "probe", ClientSecret: "wrong", Scopes: []string{"read"}}
+	if outcome := r.run(); outcome.Err == nil || outcome.Err.Kind != ErrorKindAuth {
+		t.Error("OAuth2 token failure not reported")
+	}
+}
+
+func TestOAuth2Proxy(t *testing.T) {
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Host == "tokens.invalid":
+			_, _ = w.Write([]byte(`{"access_token":"proxied","expires_in":3600}`))
+		case r.URL.Host == "api.invalid" && r.Header.Get("Authorization") == "Bearer proxied":
+			w.WriteHeader(200)
+		default:
+			w.WriteHeader(400)
+		}
+	}))
+	defer proxy.Close()
+	r := newRequester("GET", "http://api.invalid/", map[string]string{}, []byte{}, Duration{5 * time.Second}, false,
+		[]string{}, []string{})
+	r.Proxy = &Proxy{Url: proxy.URL}
+	r.OAuth2 = &OAuth2{TokenUrl: "http://tokens.invalid/token", ClientId: "probe", ClientSecret: "secret"}
+	if outcome := r.run(); outcome.Err != nil || outcome.StatusCode != 200 {
+		t.Error("OAuth2 token not requested through the proxy", outcome.Err)
+	}
+}
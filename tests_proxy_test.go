@@ -0,0 +1,183 @@
+package main
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestHttpProxy(t *testing.T) {
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !r.URL.IsAbs() || r.URL.Host != "redprobe.invalid" {
+			w.WriteHeader(400)
+			return
+		}
+		if r.Header.Get("Proxy-Authorization") != "Basic Zm9vOmJhcg==" {
+			w.WriteHeader(407)
+			return
+		}
+		w.WriteHeader(200)
+	}))
+	defer proxy.Close()
+	r := newRequester("GET", "http://redprobe.invalid/", map[string]string{}, []byte{}, Duration{5 * time.Second},
+		false, []string{}, []string{})
+	r.Proxy = &Proxy{Url: proxy.URL, Username: "foo", Password: "bar"}
+	outcome := r.run()
+	if outcome.StatusCode != 200 {
+		t.Error("Request did not go through the proxy")
+	}
+	r.Proxy = &Proxy{Url: "ftp://localhost"}
+	if outcome = r.run(); outcome.Err == nil {
+		t.Error("Invalid proxy scheme accepted")
+	}
+}
+
+func TestSocks5Proxy(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Host != "redprobe.invalid" {
+			w.WriteHeader(400)
+		}
+	}))
+	defer server.Close()
+	for _, credentials := range [][2]string{{"", ""}, {"foo", "bar"}} {
+		proxy := socks5Proxy(t, server.Listener.Addr().String(), credentials[0], credentials[1])
+		r := newRequester("GET", "http://redprobe.invalid/", map[string]string{}, []byte{},
+			Duration{5 * time.Second}, false, []string{}, []string{})
+		r.Proxy = &Proxy{Url: "socks5://" + proxy, Username: credentials[0], Password: credentials[1]}
+		if outcome := r.run(); outcome.StatusCode != 200 {
+			t.Error("Request did not go through the SOCKS5 proxy", credentials[0], outcome.Err)
+		}
+		if credentials[0] == "" {
+			continue
+		}
+		r.Proxy.Password = "wrong"
+		if outcome := r.run(); outcome.Err == nil {
+			t.Error("Wrong SOCKS5 credentials accepted")
+		}
+	}
+}
+
+// socks5Proxy starts a minimal SOCKS5 proxy, requiring the username and password when set, that connects the requests
+// for redprobe.invalid to the target address. It returns the address of the proxy
+func socks5Proxy(t *testing.T, target string, username string, password string) string {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = listener.Close() })
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				destination, err := socks5Handshake(conn, username, password)
+				if err != nil || destination != "redprobe.invalid:80" {
+					return
+				}
+				upstream, err := net.Dial("tcp", target)
+				if err != nil {
+					return
+				}
+				defer upstream.Close()
+				_, _ = conn.Write([]byte{5, 0, 0, 1, 0, 0, 0, 0, 0, 0})
+				go func() { _, _ = io.Copy(upstream, conn) }()
+				_, _ = io.Copy(conn, upstream)
+			}()
+		}
+	}()
+	return listener.Addr().String()
+}
+
+// socks5Handshake negotiates the authentication method, checks the credentials, and returns the destination of the
+// CONNECT request
+func socks5Handshake(conn net.Conn, username string, password string) (string, error) {
+	methods, err := socks5Read(conn, 2)
+	if err != nil {
+		return "", err
+	}
+	if _, err := socks5Read(conn, int(methods[1])); err != nil {
+		return "", err
+	}
+	if username == "" {
+		if _, err := conn.Write([]byte{5, 0}); err != nil {
+			return "", err
+		}
+		return socks5Destination(conn)
+	}
+	if _, err := conn.Write([]byte{5, 2}); err != nil {
+		return "", err
+	}
+	if _, err := socks5Read(conn, 1); err != nil {
+		return "", err
+	}
+	credentials := make([]string, 0)
+	for i := 0; i < 2; i++ {
+		length, err := socks5Read(conn, 1)
+		if err != nil {
+			return "", err
+		}
+		value, err := socks5Read(conn, int(length[0]))
+		if err != nil {
+			return "", err
+		}
+		credentials = append(credentials, string(value))
+	}
+	if credentials[0] != username || credentials[1] != password {
+		_, _ = conn.Write([]byte{1, 1})
+		return "", errors.New("wrong credentials")
+	}
+	if _, err := conn.Write([]byte{1, 0}); err != nil {
+		return "", err
+	}
+	return socks5Destination(conn)
+}
+
+// socks5Destination reads the CONNECT request and returns its destination, as in "redprobe.invalid:80"
+func socks5Destination(conn net.Conn) (string, error) {
+	header, err := socks5Read(conn, 4)
+	if err != nil {
+		return "", err
+	}
+	var host string
+	switch header[3] {
+	case 1, 4:
+		ip, err := socks5Read(conn, map[byte]int{1: 4, 4: 16}[header[3]])
+		if err != nil {
+			return "", err
+		}
+		host = net.IP(ip).String()
+	case 3:
+		length, err := socks5Read(conn, 1)
+		if err != nil {
+			return "", err
+		}
+		name, err := socks5Read(conn, int(length[0]))
+		if err != nil {
+			return "", err
+		}
+		host = string(name)
+	default:
+		return "", errors.New("unsupported address type")
+	}
+	port, err := socks5Read(conn, 2)
+	if err != nil {
+		return "", err
+	}
+	return net.JoinHostPort(host, strconv.Itoa(int(binary.BigEndian.Uint16(port)))), nil
+}
+
+// socks5Read reads the given number of bytes from the connection
+func socks5Read(conn net.Conn, size int) ([]byte, error) {
+	data := make([]byte, size)
+	_, err := io.ReadFull(conn, data)
+	return data, err
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestParallelConnectAttempts(t *testing.T) {
+	rt := newRedTracer()
+	rt.start = time.Now()
+	var wait sync.WaitGroup
+	for _, addr := range []string{"[::1]:443", "127.0.0.1:443"} {
+		wait.Add(1)
+		go func(addr string) {
+			defer wait.Done()
+			rt.clientTrace.ConnectStart("tcp", addr)
+		}(addr)
+	}
+	wait.Wait()
+	connStart, _ := rt.connectionPhase()
+	time.Sleep(10 * time.Millisecond)
+	rt.clientTrace.ConnectDone("tcp", "127.0.0.1:443", nil)
+	time.Sleep(10 * time.Millisecond)
+	rt.clientTrace.ConnectDone("tcp", "[::1]:443", errors.New("operation was canceled"))
+	start, done := rt.connectionPhase()
+	events := rt.timeline()
+	if len(events) != 4 || events[2].Detail != "127.0.0.1:443" {
+		t.Fatal("Connection attempts not traced", events)
+	}
+	if start != connStart || done.Sub(rt.start) != events[2].Offset || rt.conn() < 10*time.Millisecond {
+		t.Error("Connection phase not spanning the first attempt to the successful one", start, done)
+	}
+}
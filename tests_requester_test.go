@@ -2,17 +2,23 @@ package main
 
 import (
 	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 )
 
 func TestExecuteAssertions(t *testing.T) {
 	outcome := Outcome{StatusCode: 200, Metrics: Metrics{DNS: 10 * time.Second}}
-	executeAssertions([]string{
+	executeAssertions(compileExpressions("assertion", []string{
 		"Outcome.StatusCode==200",
 		"Outcome.Metrics.DNS.Seconds() > 10",
 		"Outcome.Foo",
-		"Outcome.StatusCode==200 ? \"OK\" : \"Nope\""}, &outcome)
+		"Outcome.StatusCode==200 ? \"OK\" : \"Nope\""}, nil), &outcome)
 	if !outcome.Checks[0].Success {
 		t.Error("Assertion did not pass")
 	}
@@ -55,21 +61,186 @@ func TestRequester(t *testing.T) {
 func TestJsonConversion(t *testing.T) {
 	j1 := []byte("{\"foo\":\"bar\"}")
 	res := Outcome{StatusCode: 200, bodyBytes: j1}
-	executeAssertions([]string{"Outcome.JsonMap().foo==\"bar\""}, &res)
+	executeAssertions(compileExpressions("assertion", []string{"Outcome.JsonMap().foo==\"bar\""}, nil), &res)
 	if !res.Checks[0].Success {
 		t.Error("Json conversion assertion did not work")
 	}
 	j2 := []byte("[{\"foo\":\"bar\"}]")
 	res = Outcome{StatusCode: 200, bodyBytes: j2}
-	executeAssertions([]string{"Response.JsonArray()[0].foo==\"bar\""}, &res)
+	executeAssertions(compileExpressions("assertion", []string{"Response.JsonArray()[0].foo==\"bar\""}, nil), &res)
 	if !res.Checks[0].Success {
 		t.Error("Json conversion assertion did not work")
 	}
 }
 
 func TestLoadConfig(t *testing.T) {
-	req := requesterFromConfig("sample_calls/example.yaml")
+	req := requesterFromConfig("sample_calls/example.yaml", "", "")
 	if req[0].Timeout.Duration.Seconds() != 5 {
 		t.Error("Could not parse duration from config file")
 	}
 }
+
+func TestAllAddresses(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasPrefix(r.Host, "localhost:") {
+			w.WriteHeader(200)
+		} else {
+			w.WriteHeader(400)
+		}
+	}))
+	defer server.Close()
+	r := newRequester("GET", strings.Replace(server.URL, "127.0.0.1", "localhost", 1), map[string]string{},
+		[]byte{}, Duration{5 * time.Second}, false, []string{}, []string{})
+	r.AllAddresses = true
+	outcomes := r.runAll()
+	if len(outcomes) == 0 {
+		t.Fatal("No outcomes for the resolved addresses")
+	}
+	found := false
+	for _, outcome := range outcomes {
+		if outcome.IpAddress == "127.0.0.1" {
+			found = true
+			if outcome.StatusCode != 200 {
+				t.Error("Host header not preserved when probing a specific address")
+			}
+		}
+	}
+	if !found {
+		t.Error("Address 127.0.0.1 not probed")
+	}
+}
+
+func TestIpVersion(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+	r := newRequester("GET", server.URL, map[string]string{}, []byte{}, Duration{5 * time.Second}, false,
+		[]string{"Response.IpFamily == \"IPv4\""}, []string{})
+	r.IpVersion = "both"
+	outcomes := r.runAll()
+	if len(outcomes) != 2 {
+		t.Fatal("Both address families should produce one outcome each")
+	}
+	if !outcomes[0].isSuccess() || outcomes[0].IpFamily != "IPv4" {
+		t.Error("IPv4 outcome is not correct")
+	}
+	if outcomes[1].isSuccess() || outcomes[1].Requester.IpVersion != "6" {
+		t.Error("IPv6 outcome is not correct")
+	}
+	r.IpVersion = "5"
+	if outcomes = r.runAll(); outcomes[0].Err == nil {
+		t.Error("Invalid IP version accepted")
+	}
+}
+
+func TestProtocol(t *testing.T) {
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	server.EnableHTTP2 = true
+	server.StartTLS()
+	defer server.Close()
+	r := newRequester("GET", server.URL, map[string]string{}, []byte{}, Duration{5 * time.Second}, true,
+		[]string{"Response.Protocol == \"HTTP/2.0\""}, []string{})
+	for protocol, expected := range map[string]string{"auto": "HTTP/2.0", "http2": "HTTP/2.0", "http1.1": "HTTP/1.1"} {
+		r.Protocol = protocol
+		outcome := r.run()
+		if outcome.Protocol != expected {
+			t.Errorf("Protocol %s negotiated %s", protocol, outcome.Protocol)
+		}
+		if outcome.isSuccess() != (expected == "HTTP/2.0") {
+			t.Errorf("Protocol assertion not correct for %s", protocol)
+		}
+	}
+	cleartext := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	cleartext.Config.Protocols = new(http.Protocols)
+	cleartext.Config.Protocols.SetUnencryptedHTTP2(true)
+	cleartext.Start()
+	defer cleartext.Close()
+	r = newRequester("GET", cleartext.URL, map[string]string{}, []byte{}, Duration{5 * time.Second}, false,
+		[]string{}, []string{})
+	r.Protocol = "h2c"
+	if outcome := r.run(); outcome.Protocol != "HTTP/2.0" {
+		t.Error("h2c not negotiated")
+	}
+}
+
+func TestRepeat(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+	r := newRequester("GET", server.URL, map[string]string{}, []byte{}, Duration{5 * time.Second}, false,
+		[]string{}, []string{})
+	r.Repeat = 3
+	outcomes := r.runAll()
+	if len(outcomes) != 3 {
+		t.Fatal("The call was not repeated")
+	}
+	if outcomes[0].Connection.Reused || !outcomes[1].Connection.Reused || !outcomes[2].Connection.Reused {
+		t.Error("Connection reuse not detected")
+	}
+	if outcomes[1].Metrics.RT.Nanoseconds() <= 0 || outcomes[1].Metrics.Conn != 0 {
+		t.Error("Warm connection metrics not correct")
+	}
+	if _, _, ok := keepAliveBreakdown(outcomes); !ok {
+		t.Error("Keep-alive breakdown not computed")
+	}
+}
+
+func TestSendAndWait(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.Copy(io.Discard, r.Body)
+		time.Sleep(50 * time.Millisecond)
+	}))
+	defer server.Close()
+	r := newRequester("POST", server.URL, map[string]string{}, make([]byte, 4*1024*1024),
+		Duration{5 * time.Second}, false, []string{}, []string{})
+	outcome := r.run()
+	if outcome.Metrics.Send.Nanoseconds() <= 0 {
+		t.Error("Send is not correct")
+	}
+	if outcome.Metrics.TTFB < 50*time.Millisecond || outcome.Metrics.TTFB > outcome.Metrics.RT {
+		t.Error("TTFB is not correct")
+	}
+	if har := toHar([]Outcome{outcome}); har.Log.Entries[0].Timings.Wait < 50 {
+		t.Error("HAR wait is not correct")
+	}
+}
+
+func TestEvents(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+	r := newRequester("GET", server.URL, map[string]string{}, []byte{}, Duration{5 * time.Second}, false,
+		[]string{}, []string{})
+	outcome := r.run()
+	names := make([]string, 0)
+	for i, event := range outcome.Events {
+		names = append(names, event.Name)
+		if i > 0 && event.Offset < outcome.Events[i-1].Offset {
+			t.Error("Events are not sorted")
+		}
+	}
+	if strings.Join(names, ",") != "GetConn,ConnectStart,ConnectDone,GotConn,WroteHeaders,WroteRequest,"+
+		"GotFirstResponseByte,BodyComplete" {
+		t.Error("Timeline is not correct", names)
+	}
+}
+
+func TestComposeUrl(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "calls.yaml")
+	config := "baseUrl: https://api.example.com/v1\npath: /users\nquery:\n  name: a b\n  page: \"2\"\n---\n" +
+		"path: status?verbose=true\n---\nurl: https://www.example.com/\n"
+	if err := os.WriteFile(path, []byte(config), 0600); err != nil {
+		t.Fatal(err)
+	}
+	requesters := requesterFromConfig(path, "", "")
+	if requesters[0].Url != "https://api.example.com/v1/users?name=a+b&page=2" {
+		t.Error("wrong composed URL", requesters[0].Url)
+	}
+	if requesters[1].Url != "https://api.example.com/v1/status?verbose=true" {
+		t.Error("base URL not applied to following documents", requesters[1].Url)
+	}
+	if requesters[2].Url != "https://www.example.com/" {
+		t.Error("absolute URL not preserved", requesters[2].Url)
+	}
+	requesters = requesterFromConfig(path, "https://staging.example.com", "")
+	if requesters[0].Url != "https://staging.example.com/users?name=a+b&page=2" {
+		t.Error("base URL not overridden", requesters[0].Url)
+	}
+}
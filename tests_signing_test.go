@@ -0,0 +1,74 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAwsSigning(t *testing.T) {
+	t.Setenv("AWS_ACCESS_KEY_ID", "AKIDEXAMPLE")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY")
+	t.Setenv("AWS_SESSION_TOKEN", "")
+	request, _ := http.NewRequest("GET", "https://example.amazonaws.com/", nil)
+	signing := Signing{Type: "aws", Region: "us-east-1", Service: "service"}
+	if err := signing.sign(request, []byte{}, time.Date(2015, 8, 30, 12, 36, 0, 0, time.UTC)); err != nil {
+		t.Fatal(err)
+	}
+	expected := "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/service/aws4_request, " +
+		"SignedHeaders=host;x-amz-date, Signature=5fa00fa31553b73ebf1942676e86291e8372ff2a2260956d9b8aae1d763fbf31"
+	if request.Header.Get("Authorization") != expected {
+		t.Error("wrong AWS signature", request.Header.Get("Authorization"))
+	}
+	t.Setenv("AWS_ACCESS_KEY_ID", "")
+	if err := signing.sign(request, []byte{}, time.Now()); err == nil {
+		t.Error("missing AWS credentials not reported")
+	}
+}
+
+func TestAwsCanonicalUri(t *testing.T) {
+	requestUrl, _ := url.Parse("https://example.amazonaws.com/documents and settings/")
+	if uri := canonicalUri(requestUrl, "service"); uri != "/documents%2520and%2520settings/" {
+		t.Error("path segments not encoded twice", uri)
+	}
+	if uri := canonicalUri(requestUrl, "s3"); uri != "/documents%20and%20settings/" {
+		t.Error("S3 path segments not encoded once", uri)
+	}
+	requestUrl, _ = url.Parse("https://example.amazonaws.com")
+	if uri := canonicalUri(requestUrl, "service"); uri != "/" {
+		t.Error("empty path not canonicalized", uri)
+	}
+}
+
+func TestHmacSigning(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		bodyHash := sha256.Sum256(body)
+		mac := hmac.New(sha256.New, []byte("secret"))
+		mac.Write([]byte(strings.Join([]string{r.Method, r.URL.RequestURI(), "host:" + r.Host,
+			"x-timestamp:" + r.Header.Get("X-Timestamp"), hex.EncodeToString(bodyHash[:])}, "\n")))
+		if r.Header.Get("X-Signature") != hex.EncodeToString(mac.Sum(nil)) {
+			w.WriteHeader(401)
+		}
+	}))
+	defer server.Close()
+	t.Setenv("PROBE_SIGNING_KEY", "secret")
+	r := newRequester("POST", server.URL+"/path?a=1", map[string]string{"X-Timestamp": "1700000000"},
+		[]byte(`{"a":1}`), Duration{5 * time.Second}, false, []string{}, []string{})
+	r.Signing = &Signing{Type: "hmac", Key: "${PROBE_SIGNING_KEY}", Headers: []string{"host", "x-timestamp"},
+		Encoding: "hex"}
+	if outcome := r.run(); outcome.StatusCode != 200 {
+		t.Error("wrong HMAC signature")
+	}
+	r.Signing.Type = "other"
+	if outcome := r.run(); outcome.Err == nil || outcome.Err.Kind != ErrorKindConfig {
+		t.Error("invalid signing type not reported")
+	}
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPhaseTimeouts(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/body" {
+			_, _ = w.Write([]byte("partial"))
+			w.(http.Flusher).Flush()
+		}
+		time.Sleep(200 * time.Millisecond)
+	}))
+	defer server.Close()
+	r := newRequester("GET", server.URL, map[string]string{}, []byte{}, Duration{5 * time.Second}, false,
+		[]string{}, []string{})
+	r.TtfbTimeout = Duration{50 * time.Millisecond}
+	assertPhaseTimeout(t, r.run(), "ttfb")
+	r.TtfbTimeout = Duration{}
+	r.DnsTimeout = Duration{time.Second}
+	r.Url = strings.Replace(server.URL, "127.0.0.1", "localhost", 1)
+	if outcome := r.run(); outcome.StatusCode != 200 || outcome.Metrics.DNS <= 0 {
+		t.Error("Call with DNS timeout failed")
+	}
+	if addresses, err := r.lookup(context.Background(), "localhost"); err != nil || len(addresses) == 0 {
+		t.Error("localhost addresses not resolved", err)
+	}
+	r.BodyTimeout = Duration{50 * time.Millisecond}
+	r.Url = server.URL + "/body"
+	assertPhaseTimeout(t, r.run(), "body")
+
+	listener, _ := net.Listen("tcp", "127.0.0.1:0")
+	defer listener.Close()
+	go func() {
+		for {
+			if _, err := listener.Accept(); err != nil {
+				return
+			}
+		}
+	}()
+	r = newRequester("GET", "https://"+listener.Addr().String(), map[string]string{}, []byte{},
+		Duration{5 * time.Second}, false, []string{}, []string{})
+	r.TlsTimeout = Duration{50 * time.Millisecond}
+	assertPhaseTimeout(t, r.run(), "tls")
+}
+
+func assertPhaseTimeout(t *testing.T, outcome Outcome, phase string) {
+	var timeoutErr *PhaseTimeoutError
+	if outcome.Err == nil || !errors.As(outcome.Err.Err, &timeoutErr) {
+		t.Errorf("No %s timeout reported", phase)
+		return
+	}
+	if timeoutErr.Phase != phase {
+		t.Errorf("Timeout reported for phase %s instead of %s", timeoutErr.Phase, phase)
+	}
+}
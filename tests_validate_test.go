@@ -0,0 +1,73 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestValidateConfig(t *testing.T) {
+	dir := writeConfigFiles(t, map[string]string{
+		"valid.yaml": "defaults:\n  timeout: 5s\n---\nurl: https://www.example.com/${r}\nmatrix:\n  r: [a, b]\n" +
+			"assertions:\n  - Response.StatusCode == 200\n  - Matrix.r == \"a\"\n",
+		"invalid.yaml": "url: https://www.example.com\ntimout: 3s\nauth:\n  type: basic\n  usrname: probe\n" +
+			"assertions:\n  - Response.StatusCod == 200\n---\nurl: ftp://www.example.com\n---\n" +
+			"url: https://www.example.com\ntimeout: 5x\nauth:\n  type: bearer\n",
+	})
+	probes, errs := validateConfig(filepath.Join(dir, "valid.yaml"), "", "")
+	if probes != 2 || len(errs) != 0 {
+		t.Error("valid configuration not validated", probes, errs)
+	}
+	_, errs = validateConfig(filepath.Join(dir, "invalid.yaml"), "", "")
+	messages := make([]string, 0)
+	for _, err := range errs {
+		messages = append(messages, strings.SplitN(err.Error(), "\n", 2)[0])
+	}
+	for _, expected := range []string{
+		"invalid.yaml, document 1, line 5: unknown key auth.usrname",
+		"invalid.yaml, document 1, line 2: unknown key timout",
+		"invalid.yaml, document 1, line 3: basic auth requires a username",
+		"invalid.yaml, document 1, line 7: invalid assertion \"Response.StatusCod == 200\"",
+		"invalid.yaml, document 2, line 9: invalid URL ftp://www.example.com",
+		"invalid.yaml, document 3, line 12: invalid duration 5x",
+		"invalid.yaml, document 3, line 13: bearer auth requires a token",
+	} {
+		found := false
+		for _, message := range messages {
+			found = found || strings.Contains(message, expected)
+		}
+		if !found {
+			t.Error("error not reported:", expected, messages)
+		}
+	}
+}
+
+func TestValidateLocations(t *testing.T) {
+	dir := writeConfigFiles(t, map[string]string{
+		"calls.yaml": "defaults:\n  timeout: -1s\n  retries: 2\n...\n---\nproxy:\n  url: http://proxy.example.com\n" +
+			"url: ftp://www.example.com\n...\n---\nurl: https://www.example.com/${r}\nmatrix:\n  r: [a, b]\n" +
+			"assertions:\n  - Response.StatusCode == 200\n  - Response.${r} == 1\n  - include: more.yaml\n",
+		"more.yaml": "- Response.Size > 0\n- Response.Sise > 0\n",
+	})
+	_, errs := validateConfig(filepath.Join(dir, "calls.yaml"), "", "")
+	messages := make([]string, 0)
+	for _, err := range errs {
+		messages = append(messages, strings.SplitN(err.Error(), "\n", 2)[0])
+	}
+	for _, expected := range []string{
+		"calls.yaml, document 1, line 2: timeout must be positive",
+		"calls.yaml, document 1, line 3: unknown key retries",
+		"calls.yaml, document 2, line 8: invalid URL ftp://www.example.com",
+		"calls.yaml, document 3, line 16: invalid assertion \"Response.a == 1\"",
+		"calls.yaml, document 3, line 16: invalid assertion \"Response.b == 1\"",
+		"more.yaml, line 2: invalid assertion \"Response.Sise > 0\"",
+	} {
+		found := false
+		for _, message := range messages {
+			found = found || strings.Contains(message, expected)
+		}
+		if !found {
+			t.Error("error not reported:", expected, messages)
+		}
+	}
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWaterfallBar(t *testing.T) {
+	size := func(bar string) int {
+		if runtime.GOOS == "windows" {
+			return len(bar)
+		}
+		return strings.Count(bar, "█")
+	}
+	metrics := Metrics{DNS: 10 * time.Millisecond, Conn: 20 * time.Millisecond, TTFB: 50 * time.Millisecond,
+		Transfer: 20 * time.Millisecond}
+	if phasesTotal(metrics) != 100*time.Millisecond {
+		t.Error("Phases total is not correct")
+	}
+	if size(waterfallBar(metrics, phasesTotal(metrics), 10)) != 10 {
+		t.Error("Waterfall bar does not fill the width")
+	}
+	if size(waterfallBar(metrics, 2*phasesTotal(metrics), 10)) != 5 {
+		t.Error("Waterfall bar is not scaled")
+	}
+	if waterfallBar(metrics, 0, 10) != "" {
+		t.Error("Waterfall bar with no scale is not empty")
+	}
+}
+
+func TestWaterfallLabel(t *testing.T) {
+	outcome := Outcome{Requester: Requester{Method: "GET", Url: "https://www.example.com/login", Name: "login",
+		Description: "The login page is up", Tags: []string{"smoke"}, RowIndex: 2}, IpAddress: "127.0.0.1"}
+	if label := waterfallLabel(outcome); label != "#2 login: GET https://www.example.com/login 127.0.0.1 (0s) - "+
+		"The login page is up [smoke]" {
+		t.Error("wrong waterfall label", label)
+	}
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"time"
+)
+
+// PhaseTimeoutError is the error returned when a phase of the call exceeds its own timeout
+type PhaseTimeoutError struct {
+	Phase   string
+	Timeout time.Duration
+	Err     error
+}
+
+// Error returns the error in string form, naming the phase that exceeded its timeout
+func (e *PhaseTimeoutError) Error() string {
+	return fmt.Sprintf("%s timeout of %s exceeded: %s", e.Phase, e.Timeout, e.Err.Error())
+}
+
+// Unwrap returns the underlying error
+func (e *PhaseTimeoutError) Unwrap() error {
+	return e.Err
+}
+
+// phaseTimeout returns the timeout set for the given phase, or zero if none is set
+func (r *Requester) phaseTimeout(phase string) time.Duration {
+	switch phase {
+	case "dns":
+		return r.DnsTimeout.Duration
+	case "connect":
+		return r.ConnectTimeout.Duration
+	case "tls":
+		return r.TlsTimeout.Duration
+	case "ttfb":
+		return r.TtfbTimeout.Duration
+	case "body":
+		return r.BodyTimeout.Duration
+	}
+	return 0
+}
+
+// phaseTimeoutError turns the error into a PhaseTimeoutError when it's a timeout and the phase the call was going
+// through has been running longer than the timeout set for it
+func (r *Requester) phaseTimeoutError(rt *RedTracer, err error) error {
+	phase, start := rt.phase()
+	timeout := r.phaseTimeout(phase)
+	if timeout <= 0 || !isTimeout(err) || time.Since(start) < timeout {
+		return err
+	}
+	return &PhaseTimeoutError{Phase: phase, Timeout: timeout, Err: err}
+}
+
+// isTimeout returns true when the error is caused by a timeout
+func isTimeout(err error) bool {
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+	return errors.Is(err, context.DeadlineExceeded)
+}
+
+// lookup resolves the host to its IP addresses, honouring the IP version and the DNS timeout
+func (r *Requester) lookup(ctx context.Context, host string) ([]string, error) {
+	if r.DnsTimeout.Duration > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, r.DnsTimeout.Duration)
+		defer cancel()
+	}
+	ips, err := net.DefaultResolver.LookupIP(ctx, r.network("ip"), host)
+	if err != nil {
+		return nil, err
+	}
+	addresses := make([]string, 0)
+	for _, ip := range ips {
+		addresses = append(addresses, ip.String())
+	}
+	return addresses, nil
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"time"
+)
+
+// runValidate validates the configuration file, printing the errors found. Returns the exit code of the process
+func runValidate(path string, baseUrl string, environment string) int {
+	probes, errs := validateConfig(path, baseUrl, environment)
+	for _, err := range errs {
+		fmt.Println(err.Error())
+	}
+	if len(errs) > 0 {
+		fmt.Printf("%d error(s) found\n", len(errs))
+		return exitCodes[ErrorKindConfig]
+	}
+	fmt.Printf("Configuration is valid, %d probe(s) defined\n", probes)
+	return 0
+}
+
+// validateConfig validates the configuration file, returning the number of probes it defines and all the errors found.
+// On top of loading the documents, which rejects unknown keys, the settings of all the environments are decoded, URLs,
+// durations, authentication, protocols and bodies are verified, and assertions and annotations are compiled against
+// the outcome environment
+func validateConfig(path string, baseUrl string, environment string) (int, []error) {
+	documents, err := loadDocuments(path, []string{})
+	if err != nil {
+		return 0, []error{err}
+	}
+	loader := newConfigLoader(baseUrl, environment)
+	probes := 0
+	errs := make([]error, 0)
+	for _, document := range documents {
+		errs = append(errs, validateEnvironments(document)...)
+		requesters, err := loader.load(document)
+		if err != nil {
+			errs = append(errs, splitErrors(err)...)
+		}
+		probes += len(requesters)
+		cache := programCache{}
+		for _, requester := range requesters {
+			errs = append(errs, validateRequester(requester, cache)...)
+		}
+	}
+	if err := loader.checkEnvironment(path); err != nil {
+		errs = append(errs, err)
+	}
+	return probes, uniqueErrors(errs)
+}
+
+// validateEnvironments decodes the settings of all the environments of the document, as only the selected one gets
+// merged into the requesters
+func validateEnvironments(document configDocument) []error {
+	environments, ok := document.values["environments"].(map[interface{}]interface{})
+	if !ok {
+		return nil
+	}
+	errs := make([]error, 0)
+	for name := range environments {
+		settings, _, err := environmentSettings(environments, fmt.Sprint(name))
+		if err != nil {
+			errs = append(errs, document.error(err))
+			continue
+		}
+		source := document.source.at("environments", fmt.Sprint(name))
+		if _, _, err := decodeRequester(settings, source, ""); err != nil {
+			errs = append(errs, splitErrors(err)...)
+		}
+	}
+	return errs
+}
+
+// validateRequester verifies the URL, the durations, the authentication, the protocol and the body of the requester,
+// and compiles its assertions and annotations, reusing the programs of the cache. The errors point at the source of the
+// values
+func validateRequester(requester Requester, cache programCache) []error {
+	source := requester.source
+	errs := make([]error, 0)
+	if parsedUrl, err := url.Parse(requester.Url); err != nil {
+		errs = append(errs, source.at("url").error(err))
+	} else if (parsedUrl.Scheme != "http" && parsedUrl.Scheme != "https") || parsedUrl.Host == "" {
+		errs = append(errs, source.at("url").error(fmt.Errorf("invalid URL %s", requester.Url)))
+	}
+	if requester.Timeout.Duration <= 0 {
+		errs = append(errs, source.at("timeout").error(errors.New("timeout must be positive")))
+	}
+	phaseTimeouts := map[string]time.Duration{"dnsTimeout": requester.DnsTimeout.Duration,
+		"connectTimeout": requester.ConnectTimeout.Duration, "tlsTimeout": requester.TlsTimeout.Duration,
+		"ttfbTimeout": requester.TtfbTimeout.Duration, "bodyTimeout": requester.BodyTimeout.Duration}
+	for _, name := range []string{"dnsTimeout", "connectTimeout", "tlsTimeout", "ttfbTimeout", "bodyTimeout"} {
+		if phaseTimeouts[name] < 0 {
+			errs = append(errs, source.at(name).error(fmt.Errorf("%s can't be negative", name)))
+		}
+	}
+	if err := requester.Auth.validate(); err != nil {
+		errs = append(errs, source.at("auth").error(err))
+	}
+	if requester.Protocol != "http3" {
+		if _, err := requester.protocols(); err != nil {
+			errs = append(errs, source.at("protocol").error(err))
+		}
+	}
+	if _, err := requester.buildBody(); err != nil {
+		errs = append(errs, source.withoutLine().error(err))
+	}
+	for _, failed := range requester.compile(cache) {
+		errs = append(errs, source.at(failed.kind+"s", failed.index).error(failed.error()))
+	}
+	return errs
+}
+
+// uniqueErrors removes the duplicate errors, as documents expanded into multiple requesters repeat them
+func uniqueErrors(errs []error) []error {
+	seen := map[string]bool{}
+	unique := make([]error, 0)
+	for _, err := range errs {
+		if !seen[err.Error()] {
+			seen[err.Error()] = true
+			unique = append(unique, err)
+		}
+	}
+	return unique
+}
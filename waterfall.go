@@ -0,0 +1,129 @@
+package main
+
+import (
+	"fmt"
+	"github.com/olekukonko/tablewriter"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// Phase is one of the timing phases composing the waterfall
+type Phase struct {
+	Name     string
+	Duration time.Duration
+	color    int
+	symbol   string
+}
+
+// phases returns the timing phases of the metrics, in the order they happen
+func phases(metrics Metrics) []Phase {
+	return []Phase{
+		{"Blocked", metrics.Blocked, 90, "b"},
+		{"DNS", metrics.DNS, 36, "d"},
+		{"Conn", metrics.Conn, 33, "c"},
+		{"Proxy", metrics.Proxy, 35, "p"},
+		{"TLS", metrics.TLS, 34, "t"},
+		{"Send", metrics.Send, 96, "s"},
+		{"TTFB", metrics.TTFB, 93, "w"},
+		{"Transfer", metrics.Transfer, 32, "r"},
+	}
+}
+
+// phasesTotal returns the sum of the timing phases of the metrics
+func phasesTotal(metrics Metrics) time.Duration {
+	var total time.Duration
+	for _, phase := range phases(metrics) {
+		total += phase.Duration
+	}
+	return total
+}
+
+// render renders the phase as a segment of the given size. Colors are not used on Windows, so each phase gets its own
+// symbol instead
+func (p Phase) render(size int) string {
+	if runtime.GOOS == "windows" {
+		return strings.Repeat(p.symbol, size)
+	}
+	return fmt.Sprintf("\x1b[%dm%s\x1b[0m", p.color, strings.Repeat("█", size))
+}
+
+// waterfallBar renders the metrics as a horizontal bar of the given width, where each phase takes the space
+// proportional to its duration. The scale is the duration represented by the whole width
+func waterfallBar(metrics Metrics, scale time.Duration, width int) string {
+	if scale <= 0 || width <= 0 {
+		return ""
+	}
+	bar := ""
+	var elapsed time.Duration
+	position := 0
+	for _, phase := range phases(metrics) {
+		elapsed += phase.Duration
+		end := int(float64(width) * float64(elapsed) / float64(scale))
+		if end > width {
+			end = width
+		}
+		if end > position {
+			bar += phase.render(end - position)
+			position = end
+		}
+	}
+	return bar
+}
+
+// waterfallLegend renders the legend of the waterfall, listing each phase with its color or symbol
+func waterfallLegend() string {
+	legend := make([]string, 0)
+	for _, phase := range phases(Metrics{}) {
+		legend = append(legend, phase.render(1)+phase.Name)
+	}
+	return strings.Join(legend, " ")
+}
+
+// tablePrintWaterfallToCLI prints the outcomes in compact form, stacking the waterfall of each outcome so that they
+// can be compared. All waterfalls share the same scale
+func tablePrintWaterfallToCLI(outcomes []Outcome) {
+	var scale time.Duration
+	for _, outcome := range outcomes {
+		if total := phasesTotal(outcome.Metrics); total > scale {
+			scale = total
+		}
+	}
+	table := buildTable("Outcome", "Waterfall")
+	for _, outcome := range outcomes {
+		label := waterfallLabel(outcome)
+		bar := waterfallBar(outcome.Metrics, scale, terminalWidth()/2-10)
+		if outcome.isSuccess() && len(outcome.Warnings) > 0 {
+			appendWarning(table, label, bar)
+		} else if outcome.isSuccess() {
+			appendSuccess(table, label, bar)
+		} else {
+			appendError(table, label, bar)
+		}
+	}
+	if len(outcomes) > 0 && outcomes[0].Requester.Environment != "" {
+		table.Append([]string{"Environment", outcomes[0].Requester.Environment})
+	}
+	table.Append([]string{"Legend", waterfallLegend()})
+	table.SetBorders(tablewriter.Border{Left: true, Right: true, Top: true, Bottom: true})
+	table.Render()
+}
+
+// waterfallLabel describes the outcome in the compact format, with its name, description, tags and dataset row
+func waterfallLabel(outcome Outcome) string {
+	label := fmt.Sprintf("%s %s %s (%s)", outcome.Requester.Method, outcome.Requester.Url, outcome.IpAddress,
+		phasesTotal(outcome.Metrics).Round(time.Microsecond).String())
+	if outcome.Requester.Name != "" {
+		label = fmt.Sprintf("%s: %s", outcome.Requester.Name, label)
+	}
+	if outcome.Requester.Description != "" {
+		label = fmt.Sprintf("%s - %s", label, outcome.Requester.Description)
+	}
+	if len(outcome.Requester.Tags) > 0 {
+		label = fmt.Sprintf("%s [%s]", label, strings.Join(outcome.Requester.Tags, ", "))
+	}
+	if outcome.Requester.RowIndex > 0 {
+		label = fmt.Sprintf("#%d %s", outcome.Requester.RowIndex, label)
+	}
+	return label
+}